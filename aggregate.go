@@ -2,10 +2,55 @@ package historia
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"time"
 )
 
-// ErrAggregateAlreadyExists returned if the aggregateID is set more than one time
-var ErrAggregateAlreadyExists = errors.New("its not possible to set ID on already existing aggregate")
+var (
+	// ErrAggregateAlreadyExists returned if the aggregateID is set more than one time
+	ErrAggregateAlreadyExists = errors.New("its not possible to set ID on already existing aggregate")
+
+	// ErrInvariantViolation matches every *InvariantError
+	ErrInvariantViolation = errors.New("aggregate invariant violated")
+)
+
+// InvariantError returned when the state of an aggregate doesn't satisfy its invariants
+type InvariantError struct {
+	AggregateID   string
+	AggregateType string
+	Err           error
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("invariant of %s %s violated: %v", e.AggregateType, e.AggregateID, e.Err)
+}
+
+func (e *InvariantError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrInvariantViolation) hold for every InvariantError
+func (e *InvariantError) Is(target error) bool {
+	return target == ErrInvariantViolation
+}
+
+// TransitionError returned when an event can't be applied on an aggregate
+type TransitionError struct {
+	AggregateID   string
+	AggregateType string
+	Version       Version
+	Reason        string
+	Err           error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("transition of %s %s failed at version %d (%s): %v", e.AggregateType, e.AggregateID, e.Version, e.Reason, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
 
 // Version represents a version number
 type Version uint64
@@ -28,6 +73,15 @@ type AggregateBase struct {
 	id      string
 	version Version
 	events  []Event
+
+	nowFunc     func() time.Time
+	idFunc      func() string
+	eventIDFunc EventIDFunc
+
+	router *EventRouter
+
+	// checkpoint is a copy of the aggregate taken before the first unsaved change was tracked
+	checkpoint reflect.Value
 }
 
 // ID returns the aggregate ID as a string
@@ -44,6 +98,25 @@ func (a *AggregateBase) SetID(id string) error {
 	return nil
 }
 
+// SetNowFunc sets the clock used when tracking changes on this aggregate,
+// taking precedence over the package level clock.
+func (a *AggregateBase) SetNowFunc(f func() time.Time) {
+	a.nowFunc = f
+}
+
+// SetIDFunc sets the generator used for the aggregate and event IDs of this aggregate,
+// taking precedence over the package level generator.
+// Event IDs are only generated with it when there is no event ID generator.
+func (a *AggregateBase) SetIDFunc(f func() string) {
+	a.idFunc = f
+}
+
+// SetEventIDFunc sets the generator used for the event IDs of this aggregate,
+// taking precedence over the package level event ID generator.
+func (a *AggregateBase) SetEventIDFunc(f EventIDFunc) {
+	a.eventIDFunc = f
+}
+
 // Version return the version based on events that are not stored
 func (a *AggregateBase) Version() Version {
 	if len(a.events) == 0 {
@@ -67,41 +140,71 @@ func (a *AggregateBase) HasUnsavedEvents() bool {
 }
 
 // TrackChange is used internally by behaviour methods to apply state changes for later persistence.
-func (a *AggregateBase) TrackChange(aggregate Aggregate, data interface{}) {
-	a.TrackChangeWithMetadata(aggregate, data, nil)
+func (a *AggregateBase) TrackChange(aggregate Aggregate, data interface{}) error {
+	return a.TrackChangeWithMetadata(aggregate, data, nil)
 }
 
 // TrackChangeWithMetadata is used internally by behaviour methods to apply state changes for later persistence.
 // metadata is handled by this func to store unrelated application state.
-func (a *AggregateBase) TrackChangeWithMetadata(aggregate Aggregate, data interface{}, metadata map[string]interface{}) {
+// If the transition of the event fails the event is not tracked and a *TransitionError is returned.
+// If the aggregate invariants don't hold after the transition, all unsaved changes are discarded,
+// the aggregate is rolled back to its last saved state and an *InvariantError is returned.
+func (a *AggregateBase) TrackChangeWithMetadata(aggregate Aggregate, data interface{}, metadata map[string]interface{}) error {
+	if !a.HasUnsavedEvents() {
+		a.saveCheckpoint(aggregate)
+	}
+
 	// This can be overwritten in the constructor of the aggregate
 	if a.id == emptyAggregateID {
-		a.id = idFunc()
+		a.id = a.newID()
 	}
 
 	name := formatAggregatePathType(aggregate)
 
+	version := a.nextVersion()
 	event := Event{
-		ID:            NewID(),
+		ID:            a.newEventID(name, version, data),
 		AggregateID:   a.id,
-		Version:       a.nextVersion(),
+		Version:       version,
 		AggregateType: name,
-		Timestamp:     timeNow(),
+		Timestamp:     a.now(),
 		Data:          data,
 		Metadata:      metadata,
 	}
 	a.events = append(a.events, event)
-	aggregate.Transition(event)
+	if err := a.transition(aggregate, event); err != nil {
+		a.events = a.events[:len(a.events)-1]
+		return err
+	}
+
+	if err := a.checkInvariants(aggregate); err != nil {
+		a.rollback(aggregate)
+		return err
+	}
+	return nil
 }
 
-// BuildFromHistory builds the aggregate state from events
-func (a *AggregateBase) BuildFromHistory(aggregate Aggregate, events []Event) {
+// BuildFromHistory builds the aggregate state from events.
+// It stops at the first event that can't be applied returning a *TransitionError,
+// the version of the aggregate is then the one of the last applied event.
+func (a *AggregateBase) BuildFromHistory(aggregate Aggregate, events []Event) error {
 	for i := range events {
 		event := events[i]
-		aggregate.Transition(event)
+		if err := a.transition(aggregate, event); err != nil {
+			return err
+		}
 		a.id = event.AggregateID
 		a.version = event.Version
 	}
+	return nil
+}
+
+// DiscardChanges drops the unsaved events and restores the aggregate to the state it had
+// before the first of them was tracked, so it can be reused after a failed command.
+// State changed in place behind pointers or in maps is only restored for aggregates implementing Cloner,
+// use Repo.Reload to rebuild other aggregates from the store.
+func (a *AggregateBase) DiscardChanges(aggregate Aggregate) {
+	a.rollback(aggregate)
 }
 
 // Root returns the included Aggregate Root state, and is used from the interface Aggregate.
@@ -109,10 +212,127 @@ func (a *AggregateBase) Root() *AggregateBase {
 	return a
 }
 
+// transition applies the event on the aggregate, through its router when the aggregate is EventRouted
+// or with TransitionE when it is a TransitionerE.
+func (a *AggregateBase) transition(aggregate Aggregate, event Event) error {
+	var err error
+	switch agg := aggregate.(type) {
+	case EventRouted:
+		if a.router == nil {
+			a.router = NewEventRouter()
+			agg.Routes(a.router)
+		}
+		err = a.router.Route(event)
+	case TransitionerE:
+		err = agg.TransitionE(event)
+	default:
+		aggregate.Transition(event)
+	}
+
+	if err != nil {
+		return &TransitionError{
+			AggregateID:   event.AggregateID,
+			AggregateType: event.AggregateType,
+			Version:       event.Version,
+			Reason:        event.Reason(),
+			Err:           err,
+		}
+	}
+	return nil
+}
+
+// checkInvariants runs the invariants declared by the aggregate
+func (a *AggregateBase) checkInvariants(aggregate Aggregate) error {
+	var err error
+	if v, ok := aggregate.(Validator); ok {
+		err = v.Validate()
+	}
+
+	if h, ok := aggregate.(InvariantHolder); ok && err == nil {
+		for _, rule := range h.Invariants() {
+			if err = rule(); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		return &InvariantError{
+			AggregateID:   a.id,
+			AggregateType: formatAggregatePathType(aggregate),
+			Err:           err,
+		}
+	}
+	return nil
+}
+
+// saveCheckpoint keeps a copy of the aggregate to roll back to, made by its Clone method when it is a Cloner
+func (a *AggregateBase) saveCheckpoint(aggregate Aggregate) {
+	a.checkpoint = reflect.Value{}
+
+	if cp, ok := cloneAggregate(aggregate); ok {
+		a.checkpoint = cp
+	}
+}
+
+// reset sets the aggregate to its zero value, keeping the clock and ID generators
+func (a *AggregateBase) reset(aggregate Aggregate) {
+	nowFunc, idFunc, eventIDFunc := a.nowFunc, a.idFunc, a.eventIDFunc
+
+	v := reflect.ValueOf(aggregate)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+
+	a.setInternals(emptyAggregateID, 0)
+	a.router = nil
+	a.nowFunc, a.idFunc, a.eventIDFunc = nowFunc, idFunc, eventIDFunc
+}
+
+// rollback restores the aggregate to the last checkpoint, discarding the unsaved events
+func (a *AggregateBase) rollback(aggregate Aggregate) {
+	if !a.checkpoint.IsValid() {
+		a.events = []Event{}
+		return
+	}
+
+	// the aggregate base is part of the copy, restoring it also clears the events and checkpoint
+	reflect.ValueOf(aggregate).Elem().Set(a.checkpoint)
+}
+
 func (a *AggregateBase) setInternals(id string, version Version) {
 	a.id = id
 	a.version = version
 	a.events = []Event{}
+	a.checkpoint = reflect.Value{}
+}
+
+// now returns the current time from the aggregate clock, falling back to the package level one
+func (a *AggregateBase) now() time.Time {
+	if a.nowFunc != nil {
+		return a.nowFunc()
+	}
+	return timeNow()
+}
+
+// newID generates an ID with the aggregate generator, falling back to the package level one
+func (a *AggregateBase) newID() string {
+	if a.idFunc != nil {
+		return a.idFunc()
+	}
+	return idFunc()
+}
+
+// newEventID generates the ID of the event holding data at version, falling back to the aggregate ID generator
+// when there is no event ID generator for the aggregate nor the package.
+func (a *AggregateBase) newEventID(aggregateType string, version Version, data EventData) string {
+	if a.eventIDFunc != nil {
+		return a.eventIDFunc(aggregateType, a.id, version, data)
+	}
+	if eventIDFunc != nil {
+		return eventIDFunc(aggregateType, a.id, version, data)
+	}
+	return a.newID()
 }
 
 func (a *AggregateBase) nextVersion() Version {
@@ -129,6 +349,7 @@ func (a *AggregateBase) update() {
 	lastEvent := a.events[len(a.events)-1]
 	a.version = lastEvent.Version
 	a.events = []Event{}
+	a.checkpoint = reflect.Value{}
 }
 
 // path return the full name of the aggregate making it unique to other aggregates with
@@ -1,6 +1,7 @@
 package historia
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -42,6 +43,48 @@ func Test_AggregateBase_TrackChange_should_add_and_transition_event(t *testing.T
 	assert.Equal(t, now, e.Timestamp)
 }
 
+func Test_AggregateBase_TrackChange_should_use_aggregate_clock_and_id_generator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	ids := []string{"aggregate", "event1", "event2"}
+
+	p := aggAgg{}
+	p.SetNowFunc(func() time.Time { return now })
+	p.SetIDFunc(func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	})
+
+	p.TrackChange(&p, &born{Name: "Clocked"})
+	p.GrowOlder()
+
+	assert.Equal(t, "aggregate", p.ID())
+	assert.Equal(t, "event1", p.events[0].ID)
+	assert.Equal(t, "event2", p.events[1].ID)
+	assert.Equal(t, now, p.events[0].Timestamp)
+	assert.Equal(t, now, p.events[1].Timestamp)
+}
+
+func Test_AggregateBase_TrackChange_should_use_aggregate_event_id_generator(t *testing.T) {
+	t.Parallel()
+
+	track := func() aggAgg {
+		p := aggAgg{}
+		_ = p.SetID("same")
+		p.SetEventIDFunc(DeterministicEventID)
+		p.TrackChange(&p, &born{Name: "Retried"})
+		p.GrowOlder()
+		return p
+	}
+
+	first, retry := track(), track()
+	assert.Equal(t, first.events[0].ID, retry.events[0].ID)
+	assert.Equal(t, first.events[1].ID, retry.events[1].ID)
+	assert.NotEqual(t, first.events[0].ID, first.events[1].ID)
+}
+
 func Test_AggregateBase_BuildFromHistory_should_transition_and_set_id_version(t *testing.T) {
 	p := aggAgg{}
 	p.TrackChange(&p, &born{Name: "Something"})
@@ -58,6 +101,130 @@ func Test_AggregateBase_BuildFromHistory_should_transition_and_set_id_version(t
 	assert.False(t, p1.HasUnsavedEvents())
 }
 
+func Test_AggregateBase_TrackChange_should_return_TransitionE_error_and_not_track_event(t *testing.T) {
+	p := aggAggE{}
+	assert.NoError(t, p.TrackChange(&p, &born{Name: "Someone"}))
+
+	err := p.TrackChange(&p, &born{Name: "Again"})
+	var terr *TransitionError
+	assert.ErrorAs(t, err, &terr)
+	assert.ErrorIs(t, err, errAlreadyBorn)
+	assert.Equal(t, Version(2), terr.Version)
+	assert.Equal(t, "born", terr.Reason)
+
+	assert.Len(t, p.events, 1)
+	assert.Equal(t, Version(1), p.Version())
+}
+
+func Test_AggregateBase_BuildFromHistory_should_stop_at_failing_TransitionE(t *testing.T) {
+	events := []Event{
+		{AggregateID: "a", AggregateType: "aggAggE", Version: 1, Data: &born{Name: "Someone"}},
+		{AggregateID: "a", AggregateType: "aggAggE", Version: 2, Data: &agedOneYear{}},
+		{AggregateID: "a", AggregateType: "aggAggE", Version: 3, Data: &born{Name: "Again"}},
+		{AggregateID: "a", AggregateType: "aggAggE", Version: 4, Data: &agedOneYear{}},
+	}
+
+	p := aggAggE{}
+	err := p.BuildFromHistory(&p, events)
+
+	var terr *TransitionError
+	assert.ErrorAs(t, err, &terr)
+	assert.ErrorIs(t, err, errAlreadyBorn)
+	assert.Equal(t, &TransitionError{AggregateID: "a", AggregateType: "aggAggE", Version: 3, Reason: "born", Err: errAlreadyBorn}, terr)
+	assert.Equal(t, "transition of aggAggE a failed at version 3 (born): already born", err.Error())
+
+	assert.Equal(t, Version(2), p.Version())
+	assert.Equal(t, 1, p.age)
+}
+
+func Test_AggregateBase_TrackChange_should_roll_back_when_invariant_fails(t *testing.T) {
+	p := invAgg{maxAge: 2}
+	assert.NoError(t, p.TrackChange(&p, &born{Name: "Someone"}))
+	p.update()
+
+	assert.NoError(t, p.TrackChange(&p, &agedOneYear{}))
+	assert.NoError(t, p.TrackChange(&p, &agedOneYear{}))
+
+	err := p.TrackChange(&p, &agedOneYear{})
+	var ierr *InvariantError
+	assert.ErrorAs(t, err, &ierr)
+	assert.ErrorIs(t, err, ErrInvariantViolation)
+	assert.ErrorIs(t, err, errTooOld)
+	assert.Equal(t, formatAggregatePathType(&p), ierr.AggregateType)
+
+	// the whole unsaved batch is discarded
+	assert.False(t, p.HasUnsavedEvents())
+	assert.Equal(t, Version(1), p.Version())
+	assert.Equal(t, 0, p.age)
+	assert.Equal(t, "Someone", p.name)
+
+	// the aggregate can be used again
+	assert.NoError(t, p.TrackChange(&p, &agedOneYear{}))
+	assert.Equal(t, Version(2), p.Version())
+	assert.Equal(t, 1, p.age)
+}
+
+func Test_AggregateBase_TrackChange_should_roll_back_state_changed_in_place_with_Clone(t *testing.T) {
+	svc := &deepSvc{}
+	p := clonedAgg{deepAgg: deepAgg{limit: 1, tags: map[string]int{}, svc: svc}}
+	assert.NoError(t, p.TrackChange(&p, &born{Name: "Someone"}))
+	p.update()
+
+	assert.NoError(t, p.TrackChange(&p, &agedOneYear{}))
+	assert.ErrorIs(t, p.TrackChange(&p, &agedOneYear{}), errTooOld)
+	assert.False(t, p.HasUnsavedEvents())
+
+	assert.Equal(t, 2, p.clones, "cloned once per batch of changes")
+	assert.Equal(t, map[string]int{"years": 0}, p.tags)
+	assert.Equal(t, [][]string{{"Someone"}}, p.history)
+	assert.Same(t, svc, p.svc)
+}
+
+func Test_AggregateBase_DiscardChanges_should_keep_dependencies(t *testing.T) {
+	svc := &deepSvc{}
+	p := deepAgg{limit: 10, tags: map[string]int{}, svc: svc}
+	assert.NoError(t, p.TrackChange(&p, &born{Name: "Someone"}))
+	p.update()
+
+	assert.NoError(t, p.TrackChange(&p, &agedOneYear{}))
+	p.DiscardChanges(&p)
+
+	assert.Same(t, svc, p.svc)
+	assert.Equal(t, Version(1), p.Version())
+	assert.False(t, p.HasUnsavedEvents())
+}
+
+func Test_AggregateBase_TrackChange_should_check_invariant_rules(t *testing.T) {
+	p := invAgg{maxAge: 10}
+	assert.NoError(t, p.TrackChange(&p, &born{Name: "Someone"}))
+
+	err := p.TrackChange(&p, &born{Name: ""})
+	assert.ErrorIs(t, err, errNameless)
+	assert.False(t, p.HasUnsavedEvents())
+	assert.Equal(t, "", p.name)
+	assert.Equal(t, Version(0), p.Version())
+}
+
+func Test_AggregateBase_DiscardChanges_should_restore_last_saved_state(t *testing.T) {
+	p := aggAgg{}
+	_ = p.TrackChange(&p, &born{Name: "Saved"})
+	p.update()
+
+	_ = p.TrackChange(&p, &born{Name: "Unsaved"})
+	_ = p.TrackChange(&p, &agedOneYear{})
+	p.DiscardChanges(&p)
+
+	assert.False(t, p.HasUnsavedEvents())
+	assert.Equal(t, Version(1), p.Version())
+	assert.Equal(t, "Saved", p.name)
+	assert.Equal(t, 0, p.age)
+
+	// discarding without changes keeps the state
+	p.DiscardChanges(&p)
+	assert.Equal(t, "Saved", p.name)
+	assert.Equal(t, Version(1), p.Version())
+}
+
 func Test_AggregateBase_SetID_should_return_error_when_id_is_already_set(t *testing.T) {
 	p := aggAgg{
 		AggregateBase: AggregateBase{id: "happy"},
@@ -135,4 +302,109 @@ func (p *aggAgg) GrowOlder() {
 	p.TrackChangeWithMetadata(p, &agedOneYear{}, metaData)
 }
 
+var (
+	errTooOld   = errors.New("too old")
+	errNameless = errors.New("nameless")
+)
+
+type invAgg struct {
+	aggAgg
+	maxAge int
+}
+
+func (p *invAgg) Validate() error {
+	if p.age > p.maxAge {
+		return errTooOld
+	}
+	return nil
+}
+
+func (p *invAgg) Invariants() []Invariant {
+	return []Invariant{
+		func() error {
+			if p.Version() > 0 && p.name == "" {
+				return errNameless
+			}
+			return nil
+		},
+	}
+}
+
+// deepSvc is a dependency injected in aggregates
+type deepSvc struct{}
+
+// deepAgg holds its state in maps and nested slices changed in place
+type deepAgg struct {
+	AggregateBase
+	limit   int
+	tags    map[string]int
+	history [][]string
+	svc     *deepSvc
+}
+
+func (p *deepAgg) Transition(evt Event) {
+	switch e := evt.Data.(type) {
+	case *born:
+		p.tags["years"] = 0
+		p.history = [][]string{{e.Name}}
+	case *agedOneYear:
+		p.tags["years"]++
+		p.history[0][0] += "!"
+	}
+}
+
+func (p *deepAgg) Validate() error {
+	for _, n := range p.tags {
+		if n > p.limit {
+			return errTooOld
+		}
+	}
+	return nil
+}
+
+type clonedAgg struct {
+	deepAgg
+	clones int
+}
+
+func (p *clonedAgg) Clone() Aggregate {
+	p.clones++
+	cp := *p
+	cp.tags = map[string]int{}
+	for k, v := range p.tags {
+		cp.tags[k] = v
+	}
+	cp.history = make([][]string, len(p.history))
+	for i := range p.history {
+		cp.history[i] = append([]string{}, p.history[i]...)
+	}
+	return &cp
+}
+
+var errAlreadyBorn = errors.New("already born")
+
+type aggAggE struct {
+	AggregateBase
+
+	born bool
+	age  int
+}
+
+func (p *aggAggE) Transition(Event) {
+	panic("TransitionE should be used")
+}
+
+func (p *aggAggE) TransitionE(evt Event) error {
+	switch evt.Data.(type) {
+	case *born:
+		if p.born {
+			return errAlreadyBorn
+		}
+		p.born = true
+	case *agedOneYear:
+		p.age++
+	}
+	return nil
+}
+
 // endregion
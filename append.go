@@ -0,0 +1,157 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrConcurrency matches every *ConcurrencyError
+	ErrConcurrency = errors.New("concurrency error")
+
+	// ErrInvalidExpectedVersion when appending with an expected version that is neither a version nor a known expectation
+	ErrInvalidExpectedVersion = errors.New("invalid expected version")
+)
+
+// maxAnyAppendAttempts bounds the appends retried when other writers keep appending to the stream
+const maxAnyAppendAttempts = 10
+
+// ExpectedVersion is the version a stream is expected to be at when appending events to it,
+// either an exact version or one of ExpectAny, ExpectNoStream and ExpectStreamExists.
+type ExpectedVersion int64
+
+const (
+	// ExpectAny appends the events whatever the version of the stream
+	ExpectAny ExpectedVersion = -1
+
+	// ExpectStreamExists appends the events only to a stream holding events
+	ExpectStreamExists ExpectedVersion = -2
+
+	// ExpectNoStream appends the events only when the stream holds no events yet
+	ExpectNoStream ExpectedVersion = 0
+)
+
+// ExpectVersion expects the stream to be exactly at version v
+func ExpectVersion(v Version) ExpectedVersion {
+	return ExpectedVersion(v)
+}
+
+func (e ExpectedVersion) String() string {
+	switch e {
+	case ExpectAny:
+		return "any"
+	case ExpectStreamExists:
+		return "stream exists"
+	case ExpectNoStream:
+		return "no stream"
+	}
+	return strconv.FormatInt(int64(e), 10)
+}
+
+// ConcurrencyError returned when the version of a stream isn't the expected one
+type ConcurrencyError struct {
+	AggregateID   string
+	AggregateType string
+	Expected      ExpectedVersion
+	Actual        Version
+}
+
+func (e *ConcurrencyError) Error() string {
+	return fmt.Sprintf("concurrency error on %s %s: expected version %s, actual version %d", e.AggregateType, e.AggregateID, e.Expected, e.Actual)
+}
+
+// Is makes errors.Is(err, ErrConcurrency) hold for every ConcurrencyError
+func (e *ConcurrencyError) Is(target error) bool {
+	return target == ErrConcurrency
+}
+
+// AppendToStream appends events to the stream of an aggregate without loading it,
+// after checking the stream is at the expected version.
+// Only the Data and Metadata of the events are used, their ID, aggregate, version and timestamp are set from the stream.
+// A stream at another version than the expected one results in a *ConcurrencyError, with ExpectAny the append
+// is retried at the new version of the stream when another writer appended to it in the meantime.
+// Expectations lower than ExpectStreamExists fail with ErrInvalidExpectedVersion.
+// The appended events are published to the subscribers once saved.
+func (r *Repo) AppendToStream(ctx context.Context, aggregateType, aggregateID string, expected ExpectedVersion, events []Event) error {
+	if expected < ExpectStreamExists {
+		return fmt.Errorf("%w: %d", ErrInvalidExpectedVersion, expected)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		stream, err := r.appendToStream(ctx, aggregateType, aggregateID, expected, events)
+		if expected == ExpectAny && errors.Is(err, ErrConcurrency) && attempt < maxAnyAppendAttempts && ctx.Err() == nil {
+			r.logger.Debug("stream appended concurrently, retrying",
+				LogKeyAggregateType, aggregateType,
+				LogKeyAggregateID, aggregateID,
+				LogKeyError, err,
+			)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// publish the saved events to subscribers
+		return r.publish(ctx, aggregateType, aggregateID, stream)
+	}
+}
+
+// appendToStream saves the events after the current or expected version of the stream
+func (r *Repo) appendToStream(ctx context.Context, aggregateType, aggregateID string, expected ExpectedVersion, events []Event) ([]Event, error) {
+	current := Version(0)
+	if expected == ExpectAny || expected == ExpectStreamExists {
+		info, err := r.streamInfo(ctx, aggregateID, aggregateType)
+		if err != nil && !errors.Is(err, ErrNoEvents) {
+			return nil, err
+		}
+		if err == nil && info.Deleted {
+			return nil, ErrStreamDeleted
+		}
+		if err == nil {
+			current = info.Version
+		}
+
+		if expected == ExpectStreamExists && current == 0 {
+			return nil, &ConcurrencyError{
+				AggregateID:   aggregateID,
+				AggregateType: aggregateType,
+				Expected:      expected,
+				Actual:        current,
+			}
+		}
+	} else {
+		current = Version(expected)
+	}
+
+	// the fallbacks to the package level clock and generators are the ones of aggregates
+	base := AggregateBase{id: aggregateID, nowFunc: r.nowFunc, idFunc: r.idFunc, eventIDFunc: r.eventIDFunc}
+
+	stream := make([]Event, len(events))
+	for i := range events {
+		version := current + Version(i) + 1
+		stream[i] = Event{
+			ID:            base.newEventID(aggregateType, version, events[i].Data),
+			AggregateID:   aggregateID,
+			AggregateType: aggregateType,
+			Version:       version,
+			Timestamp:     base.now(),
+			Data:          events[i].Data,
+			Metadata:      events[i].Metadata,
+		}
+	}
+
+	enrich(ctx, r.enrichers, stream)
+
+	saveCtx, end := r.observer.Save(ctx, aggregateType, aggregateID, len(stream))
+	err := r.eventStore.SaveEvents(saveCtx, stream)
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
@@ -0,0 +1,163 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExpectedVersion_String(t *testing.T) {
+	assert.Equal(t, "any", ExpectAny.String())
+	assert.Equal(t, "no stream", ExpectNoStream.String())
+	assert.Equal(t, "stream exists", ExpectStreamExists.String())
+	assert.Equal(t, "3", ExpectVersion(3).String())
+}
+
+func Test_ConcurrencyError_should_match_ErrConcurrency(t *testing.T) {
+	var err error = &ConcurrencyError{AggregateID: "1", AggregateType: "T", Expected: ExpectVersion(2), Actual: 4}
+
+	assert.ErrorIs(t, err, ErrConcurrency)
+	assert.EqualError(t, err, "concurrency error on T 1: expected version 2, actual version 4")
+}
+
+func Test_Repo_AppendToStream_should_number_events_from_expected_version(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var saved []Event
+	es := &eventStoreMocker{
+		save: func(_ context.Context, events []Event) error {
+			saved = events
+			return nil
+		},
+	}
+
+	var published []Event
+	repo := NewRepository(es, nil, WithRepositoryNowFunc(func() time.Time { return now }), WithRepositoryEventIDFunc(DeterministicEventID))
+	repo.SubscriberSpecificAggregate(func(_ context.Context, e Event) error {
+		published = append(published, e)
+		return nil
+	}, &repoAggregate{AggregateBase: AggregateBase{id: "acc"}}).Subscribe()
+
+	events := []Event{{Data: &repoEvent1{Name: "a"}, Metadata: EventMetadata{"k": "v"}}, {Data: &repoEvent1{Name: "b"}}}
+	aggregateType := formatAggregatePathType(&repoAggregate{})
+	assert.NoError(t, repo.AppendToStream(context.Background(), aggregateType, "acc", ExpectVersion(3), events))
+
+	assert.Len(t, saved, 2)
+	assert.Equal(t, Version(4), saved[0].Version)
+	assert.Equal(t, Version(5), saved[1].Version)
+	assert.Equal(t, "acc", saved[1].AggregateID)
+	assert.Equal(t, aggregateType, saved[1].AggregateType)
+	assert.Equal(t, DeterministicEventID(aggregateType, "acc", 4, &repoEvent1{Name: "a"}), saved[0].ID)
+	assert.Equal(t, now, saved[0].Timestamp)
+	assert.Equal(t, EventMetadata{"k": "v"}, saved[0].Metadata)
+	assert.Equal(t, saved, published)
+}
+
+func Test_Repo_AppendToStream_should_read_version_for_any(t *testing.T) {
+	var saved []Event
+	es := &streamInfoMocker{info: &StreamInfo{Version: 7}}
+	es.save = func(_ context.Context, events []Event) error {
+		saved = events
+		return nil
+	}
+
+	repo := NewRepository(es, nil)
+	assert.NoError(t, repo.AppendToStream(context.Background(), "T", "1", ExpectAny, []Event{{Data: &repoEvent1{}}}))
+	assert.Equal(t, Version(8), saved[0].Version)
+
+	es.err = ErrNoEvents
+	assert.NoError(t, repo.AppendToStream(context.Background(), "T", "1", ExpectAny, []Event{{Data: &repoEvent1{}}}))
+	assert.Equal(t, Version(1), saved[0].Version)
+}
+
+func Test_Repo_AppendToStream_should_retry_any_on_concurrent_append(t *testing.T) {
+	var saved []Event
+	es := &streamInfoMocker{info: &StreamInfo{Version: 7}}
+	es.save = func(_ context.Context, events []Event) error {
+		if es.info.Version == 7 {
+			// another writer appended between reading the version and saving
+			es.info = &StreamInfo{Version: 9}
+			return &ConcurrencyError{Expected: ExpectVersion(7), Actual: 9}
+		}
+		saved = events
+		return nil
+	}
+
+	repo := NewRepository(es, nil)
+	assert.NoError(t, repo.AppendToStream(context.Background(), "T", "1", ExpectAny, []Event{{Data: &repoEvent1{}}}))
+	assert.Equal(t, Version(10), saved[0].Version)
+
+	// exact expectations are not retried
+	es.info = &StreamInfo{Version: 7}
+	saved = nil
+	err := repo.AppendToStream(context.Background(), "T", "1", ExpectVersion(7), []Event{{Data: &repoEvent1{}}})
+	assert.ErrorIs(t, err, ErrConcurrency)
+	assert.Nil(t, saved)
+}
+
+func Test_Repo_AppendToStream_should_give_up_retrying_any(t *testing.T) {
+	attempts := 0
+	es := &streamInfoMocker{info: &StreamInfo{Version: 7}}
+	es.save = func(context.Context, []Event) error {
+		attempts++
+		return &ConcurrencyError{Expected: ExpectVersion(7), Actual: 8}
+	}
+
+	err := NewRepository(es, nil).AppendToStream(context.Background(), "T", "1", ExpectAny, []Event{{Data: &repoEvent1{}}})
+	assert.ErrorIs(t, err, ErrConcurrency)
+	assert.Equal(t, maxAnyAppendAttempts, attempts)
+}
+
+func Test_Repo_AppendToStream_should_reject_invalid_expected_version(t *testing.T) {
+	es := &eventStoreMocker{
+		save: func(context.Context, []Event) error {
+			t.Fatal("nothing should be saved")
+			return nil
+		},
+	}
+
+	err := NewRepository(es, nil).AppendToStream(context.Background(), "T", "1", ExpectedVersion(-3), []Event{{Data: &repoEvent1{}}})
+	assert.ErrorIs(t, err, ErrInvalidExpectedVersion)
+	assert.EqualError(t, err, "invalid expected version: -3")
+}
+
+func Test_Repo_AppendToStream_should_return_concurrency_error_when_stream_missing(t *testing.T) {
+	es := &streamInfoMocker{err: ErrNoEvents}
+	es.save = func(context.Context, []Event) error {
+		t.Fatal("nothing should be saved")
+		return nil
+	}
+
+	err := NewRepository(es, nil).AppendToStream(context.Background(), "T", "1", ExpectStreamExists, []Event{{Data: &repoEvent1{}}})
+
+	var concurrency *ConcurrencyError
+	assert.True(t, errors.As(err, &concurrency))
+	assert.Equal(t, ExpectStreamExists, concurrency.Expected)
+	assert.Equal(t, Version(0), concurrency.Actual)
+}
+
+func Test_Repo_AppendToStream_should_return_error_when_stream_deleted(t *testing.T) {
+	es := &streamInfoMocker{info: &StreamInfo{Version: 3, Deleted: true}}
+
+	err := NewRepository(es, nil).AppendToStream(context.Background(), "T", "1", ExpectAny, []Event{{Data: &repoEvent1{}}})
+	assert.ErrorIs(t, err, ErrStreamDeleted)
+}
+
+func Test_Repo_AppendToStream_should_not_publish_when_save_fails(t *testing.T) {
+	es := &eventStoreMocker{
+		save: func(context.Context, []Event) error {
+			return &ConcurrencyError{Expected: ExpectNoStream, Actual: 2}
+		},
+	}
+
+	repo := NewRepository(es, nil)
+	repo.SubscriberAll(func(context.Context, Event) error {
+		t.Fatal("nothing should be published")
+		return nil
+	}).Subscribe()
+
+	err := repo.AppendToStream(context.Background(), "T", "1", ExpectNoStream, []Event{{Data: &repoEvent1{}}})
+	assert.ErrorIs(t, err, ErrConcurrency)
+}
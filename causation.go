@@ -0,0 +1,108 @@
+package historia
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrEventNotFound when an event isn't in the event log
+	ErrEventNotFound = errors.New("event not found")
+
+	// ErrEventLogNotSupported when reading the event log through a decorator of an event store that isn't an EventLogReader
+	ErrEventLogNotSupported = errors.New("event store doesn't support reading the event log")
+)
+
+// EventLogReader is implemented by event stores able to read the events of all the aggregates,
+// in the order they were saved
+type EventLogReader interface {
+	ReadAll(ctx context.Context) ([]Event, error)
+}
+
+type eventKey struct{}
+
+// ContextWithEvent returns a context holding the event being handled,
+// event streams hand it to subscribers so the events they save are caused by it.
+func ContextWithEvent(ctx context.Context, event Event) context.Context {
+	return context.WithValue(ctx, eventKey{}, event)
+}
+
+// EventFromContext returns the event being handled placed in ctx by ContextWithEvent
+func EventFromContext(ctx context.Context) (Event, bool) {
+	event, ok := ctx.Value(eventKey{}).(Event)
+	return event, ok
+}
+
+// CausalChain returns the event with the ID and the events that caused it, following their causation IDs,
+// from the first cause to the event. The chain stops at the first cause missing from the log.
+func CausalChain(ctx context.Context, log EventLogReader, eventID string) ([]Event, error) {
+	events, err := log.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]Event, len(events))
+	for i := range events {
+		byID[events[i].ID] = events[i]
+	}
+
+	event, ok := byID[eventID]
+	if !ok {
+		return nil, ErrEventNotFound
+	}
+
+	chain := []Event{event}
+	seen := map[string]struct{}{event.ID: {}}
+	for {
+		cause, ok := byID[event.CausationID()]
+		if _, looped := seen[cause.ID]; !ok || looped {
+			break
+		}
+		seen[cause.ID] = struct{}{}
+		chain = append(chain, cause)
+		event = cause
+	}
+
+	// the chain was built from the event to its first cause
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// Correlated returns the events with the correlation ID, and the event with that ID starting the correlation,
+// in the order they were saved
+func Correlated(ctx context.Context, log EventLogReader, correlationID string) ([]Event, error) {
+	events, err := log.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var correlated []Event
+	for i := range events {
+		if events[i].ID == correlationID || events[i].CorrelationID() == correlationID {
+			correlated = append(correlated, events[i])
+		}
+	}
+	return correlated, nil
+}
+
+// causationMetadata returns the causation and correlation metadata of events caused by the event being handled:
+// the causation ID is the ID of the handled event, and the correlation ID the one of the handled event
+// or its ID when it has none, starting the correlation.
+func causationMetadata(ctx context.Context) map[string]interface{} {
+	parent, ok := EventFromContext(ctx)
+	if !ok || parent.ID == "" {
+		return nil
+	}
+
+	correlationID := parent.CorrelationID()
+	if correlationID == "" {
+		correlationID = parent.ID
+	}
+
+	return map[string]interface{}{
+		MetadataCausationID:   parent.ID,
+		MetadataCorrelationID: correlationID,
+	}
+}
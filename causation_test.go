@@ -0,0 +1,138 @@
+package historia
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EventFromContext(t *testing.T) {
+	_, ok := EventFromContext(context.Background())
+	assert.False(t, ok)
+
+	event := Event{ID: "e1"}
+	found, ok := EventFromContext(ContextWithEvent(context.Background(), event))
+	assert.True(t, ok)
+	assert.Equal(t, event, found)
+}
+
+func Test_EventStream_should_place_handled_event_in_context(t *testing.T) {
+	stream := NewEventStream()
+
+	var handled Event
+	stream.SubscriberAll(func(ctx context.Context, e Event) error {
+		handled, _ = EventFromContext(ctx)
+		return nil
+	}).Subscribe()
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	assert.NoError(t, stream.Update(context.Background(), agg, agg.Events()))
+	assert.Equal(t, agg.Events()[0], handled)
+}
+
+func Test_Repo_Save_in_subscriber_should_track_causation(t *testing.T) {
+	es := newEventLogMocker()
+	repo := NewRepository(es, nil)
+
+	// a saga reacting to the first event of an aggregate by saving another one, once
+	repo.SubscriberSpecificEvent(func(ctx context.Context, e Event) error {
+		if e.Data.(*repoEvent1).Name != "order placed" {
+			return nil
+		}
+		next := &repoAggregate{}
+		_ = next.TrackChange(next, &repoEvent1{Name: "payment requested"})
+		return repo.Save(ctx, next)
+	}, &repoEvent1{}).Subscribe()
+
+	order := &repoAggregate{}
+	_ = order.TrackChange(order, &repoEvent1{Name: "order placed"})
+	assert.NoError(t, repo.Save(WithCausationID(context.Background(), "command"), order))
+
+	log, _ := es.ReadAll(context.Background())
+	assert.Len(t, log, 2)
+	placed, requested := log[0], log[1]
+
+	assert.Equal(t, "command", placed.CausationID())
+	assert.Equal(t, "", placed.CorrelationID())
+	assert.Equal(t, placed.ID, requested.CausationID())
+	assert.Equal(t, placed.ID, requested.CorrelationID())
+}
+
+func Test_ContextMetadata_should_propagate_correlation_of_handled_event(t *testing.T) {
+	parent := Event{ID: "e2", Metadata: EventMetadata{MetadataCorrelationID: "e1"}}
+	ctx := ContextWithEvent(WithCausationID(WithUserID(context.Background(), "jane"), "command"), parent)
+
+	assert.Equal(t, map[string]interface{}{
+		MetadataCausationID:   "e2",
+		MetadataCorrelationID: "e1",
+		MetadataUserID:        "jane",
+	}, ContextMetadata(ctx))
+}
+
+func Test_CausalChain(t *testing.T) {
+	es := newEventLogMocker()
+	es.log = []Event{
+		{ID: "e1"},
+		{ID: "other"},
+		{ID: "e2", Metadata: EventMetadata{MetadataCausationID: "e1", MetadataCorrelationID: "e1"}},
+		{ID: "e3", Metadata: EventMetadata{MetadataCausationID: "e2", MetadataCorrelationID: "e1"}},
+		{ID: "loop", Metadata: EventMetadata{MetadataCausationID: "loop"}},
+	}
+
+	chain, err := CausalChain(context.Background(), es, "e3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e1", "e2", "e3"}, eventIDs(chain))
+
+	chain, err = CausalChain(context.Background(), es, "loop")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"loop"}, eventIDs(chain))
+
+	_, err = CausalChain(context.Background(), es, "unknown")
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func Test_Correlated(t *testing.T) {
+	es := newEventLogMocker()
+	es.log = []Event{
+		{ID: "e1"},
+		{ID: "other"},
+		{ID: "e2", Metadata: EventMetadata{MetadataCorrelationID: "e1"}},
+		{ID: "e3", Metadata: EventMetadata{MetadataCorrelationID: "e1"}},
+	}
+
+	events, err := Correlated(context.Background(), es, "e1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e1", "e2", "e3"}, eventIDs(events))
+}
+
+func eventIDs(events []Event) []string {
+	ids := make([]string, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+	return ids
+}
+
+// region mocks
+
+type eventLogMocker struct {
+	eventStoreMocker
+	log []Event
+}
+
+func newEventLogMocker() *eventLogMocker {
+	m := &eventLogMocker{}
+	m.save = func(_ context.Context, events []Event) error {
+		m.log = append(m.log, events...)
+		return nil
+	}
+	return m
+}
+
+func (m *eventLogMocker) ReadAll(context.Context) ([]Event, error) {
+	return m.log, nil
+}
+
+// endregion
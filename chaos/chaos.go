@@ -0,0 +1,180 @@
+// Package chaos wraps event stores, snapshot stores and event handlers to inject faults,
+// testing how applications behave on slow or failing infrastructure.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	hi "github.com/bansukai/historia"
+)
+
+var (
+	// ErrInjected is returned by partial writes of faults without error
+	ErrInjected = errors.New("chaos: injected fault")
+)
+
+// Op names an operation faults are injected into
+type Op string
+
+const (
+	OpSaveEvents   Op = "SaveEvents"
+	OpGetEvents    Op = "GetEvents"
+	OpSaveSnapshot Op = "SaveSnapshot"
+	OpGetSnapshot  Op = "GetSnapshot"
+	OpPublish      Op = "Publish"
+)
+
+// Fault describes how calls to an operation misbehave.
+// Latency is applied first, then the call either fails with Err, is dropped, or goes through.
+type Fault struct {
+	Op Op
+
+	// Nth is the first call affected by the fault, counting from 1 for each operation, 0 and 1 both being the first call
+	Nth int
+
+	// Times is the number of calls affected from Nth, 0 affecting all of them
+	Times int
+
+	// Latency delays the call, or until the context is done
+	Latency time.Duration
+
+	// Err is returned instead of making the call
+	Err error
+
+	// Drop skips the call and reports success, losing the write or the published event
+	Drop bool
+
+	// PartialWrite saves only that many events of the batch before failing, with Err or ErrInjected
+	PartialWrite int
+}
+
+// FailNth fails the nth call to op with err
+func FailNth(op Op, n int, err error) Fault {
+	return Fault{Op: op, Nth: n, Times: 1, Err: err}
+}
+
+// Concurrency fails the nth save of events with historia.ErrConcurrency
+func Concurrency(n int) Fault {
+	return FailNth(OpSaveEvents, n, hi.ErrConcurrency)
+}
+
+// Latency delays every call to op by d
+func Latency(op Op, d time.Duration) Fault {
+	return Fault{Op: op, Latency: d}
+}
+
+// PartialWrite saves only the first saved events of the nth save before failing with ErrInjected
+func PartialWrite(n int, saved int) Fault {
+	return Fault{Op: OpSaveEvents, Nth: n, Times: 1, PartialWrite: saved}
+}
+
+// DropPublish drops every event published to a Handler
+func DropPublish() Fault {
+	return Fault{Op: OpPublish, Drop: true}
+}
+
+func (f Fault) matches(op Op, call int) bool {
+	first := f.Nth
+	if first == 0 {
+		first = 1
+	}
+	return f.Op == op && call >= first && (f.Times == 0 || call < first+f.Times)
+}
+
+// Injector holds faults and counts the calls of each operation
+type Injector struct {
+	faults []Fault
+	calls  map[Op]int
+	lock   sync.Mutex
+}
+
+// NewInjector returns an injector of faults
+func NewInjector(faults ...Fault) *Injector {
+	return &Injector{
+		faults: faults,
+		calls:  make(map[Op]int),
+	}
+}
+
+// Inject adds faults, applied to the calls made from now on
+func (i *Injector) Inject(faults ...Fault) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.faults = append(i.faults, faults...)
+}
+
+// Reset removes the faults and the call counts
+func (i *Injector) Reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.faults = nil
+	i.calls = make(map[Op]int)
+}
+
+// Calls returns the number of calls made to op
+func (i *Injector) Calls(op Op) int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return i.calls[op]
+}
+
+// next counts a call to op and returns the first fault matching it
+func (i *Injector) next(op Op) (Fault, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.calls[op]++
+	for _, f := range i.faults {
+		if f.matches(op, i.calls[op]) {
+			return f, true
+		}
+	}
+	return Fault{}, false
+}
+
+// apply waits for the fault latency and reports whether the call must be skipped, with the error to return
+func (f Fault) apply(ctx context.Context) (bool, error) {
+	if err := f.wait(ctx); err != nil {
+		return true, err
+	}
+
+	if f.Err != nil {
+		return true, f.Err
+	}
+	return f.Drop, nil
+}
+
+// wait sleeps for the fault latency, or until the context is done
+func (f Fault) wait(ctx context.Context) error {
+	if f.Latency <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(f.Latency)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Handler wraps an event handler, injecting the OpPublish faults of the injector
+func Handler(f hi.EventHandlerFunc, injector *Injector) hi.EventHandlerFunc {
+	return func(ctx context.Context, event hi.Event) error {
+		if fault, ok := injector.next(OpPublish); ok {
+			if skip, err := fault.apply(ctx); skip {
+				return err
+			}
+		}
+		return f(ctx, event)
+	}
+}
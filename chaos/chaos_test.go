@@ -0,0 +1,154 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	hi "github.com/bansukai/historia"
+	"github.com/bansukai/historia/eventstore"
+	esmemory "github.com/bansukai/historia/eventstore/memory"
+	"github.com/bansukai/historia/snapshot"
+	ssmemory "github.com/bansukai/historia/snapshot/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EventStore_should_pass_acceptance_without_faults(t *testing.T) {
+	eventstore.AcceptanceTest(t, NewEventStore(esmemory.New()))
+}
+
+func Test_SnapshotStore_should_pass_acceptance_without_faults(t *testing.T) {
+	snapshot.AcceptanceTestSnapshotStore(t, NewSnapshotStore(ssmemory.New()))
+}
+
+func Test_Fault_matches(t *testing.T) {
+	f := Fault{Op: OpSaveEvents, Nth: 2, Times: 2}
+	assert.False(t, f.matches(OpSaveEvents, 1))
+	assert.True(t, f.matches(OpSaveEvents, 2))
+	assert.True(t, f.matches(OpSaveEvents, 3))
+	assert.False(t, f.matches(OpSaveEvents, 4))
+	assert.False(t, f.matches(OpGetEvents, 2))
+
+	every := Fault{Op: OpGetEvents}
+	assert.True(t, every.matches(OpGetEvents, 1))
+	assert.True(t, every.matches(OpGetEvents, 100))
+}
+
+func Test_EventStore_SaveEvents_should_fail_nth_call(t *testing.T) {
+	errBoom := errors.New("boom")
+	es := NewEventStore(esmemory.New(), FailNth(OpSaveEvents, 2, errBoom))
+	ctx := context.Background()
+
+	assert.NoError(t, es.SaveEvents(ctx, events("1", 1, 1)))
+	assert.ErrorIs(t, es.SaveEvents(ctx, events("1", 2, 1)), errBoom)
+	assert.NoError(t, es.SaveEvents(ctx, events("1", 2, 1)))
+	assert.Equal(t, 3, es.Calls(OpSaveEvents))
+
+	stored, err := es.GetEvents(ctx, "1", "T", 0)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+}
+
+func Test_EventStore_SaveEvents_should_return_concurrency_error(t *testing.T) {
+	es := NewEventStore(esmemory.New(), Concurrency(1))
+
+	err := es.SaveEvents(context.Background(), events("1", 1, 1))
+	assert.ErrorIs(t, err, hi.ErrConcurrency)
+	assert.ErrorIs(t, err, eventstore.ErrConcurrency)
+}
+
+func Test_EventStore_SaveEvents_should_write_partially(t *testing.T) {
+	es := NewEventStore(esmemory.New(), PartialWrite(1, 2))
+	ctx := context.Background()
+
+	assert.ErrorIs(t, es.SaveEvents(ctx, events("1", 1, 5)), ErrInjected)
+
+	stored, err := es.GetEvents(ctx, "1", "T", 0)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+}
+
+func Test_EventStore_SaveEvents_should_drop_write(t *testing.T) {
+	es := NewEventStore(esmemory.New(), Fault{Op: OpSaveEvents, Drop: true})
+	ctx := context.Background()
+
+	assert.NoError(t, es.SaveEvents(ctx, events("1", 1, 1)))
+
+	_, err := es.GetEvents(ctx, "1", "T", 0)
+	assert.ErrorIs(t, err, hi.ErrNoEvents)
+}
+
+func Test_EventStore_should_inject_latency(t *testing.T) {
+	es := NewEventStore(esmemory.New(), Latency(OpGetEvents, 20*time.Millisecond))
+
+	start := time.Now()
+	_, err := es.GetEvents(context.Background(), "1", "T", 0)
+	assert.ErrorIs(t, err, hi.ErrNoEvents)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	es.Inject(Latency(OpSaveEvents, time.Hour))
+	assert.ErrorIs(t, es.SaveEvents(ctx, events("1", 1, 1)), context.DeadlineExceeded)
+}
+
+func Test_Injector_Reset_should_remove_faults(t *testing.T) {
+	es := NewEventStore(esmemory.New(), Concurrency(0))
+	es.Reset()
+
+	assert.NoError(t, es.SaveEvents(context.Background(), events("1", 1, 1)))
+	assert.Equal(t, 1, es.Calls(OpSaveEvents))
+}
+
+func Test_SnapshotStore_should_inject_faults(t *testing.T) {
+	errBoom := errors.New("boom")
+	ss := NewSnapshotStore(ssmemory.New(), FailNth(OpSaveSnapshot, 1, errBoom), FailNth(OpGetSnapshot, 2, errBoom))
+	ctx := context.Background()
+	s := &hi.Snapshot{ID: "1", Type: "T", Version: 1}
+
+	assert.ErrorIs(t, ss.Save(ctx, s), errBoom)
+	assert.NoError(t, ss.Save(ctx, s))
+
+	_, err := ss.Get(ctx, "1", "T")
+	assert.NoError(t, err)
+	_, err = ss.Get(ctx, "1", "T")
+	assert.ErrorIs(t, err, errBoom)
+}
+
+func Test_Handler_should_drop_publishes(t *testing.T) {
+	var handled []hi.Event
+	injector := NewInjector(Fault{Op: OpPublish, Nth: 2, Times: 1, Drop: true})
+	h := Handler(func(_ context.Context, e hi.Event) error {
+		handled = append(handled, e)
+		return nil
+	}, injector)
+
+	for _, e := range events("1", 1, 3) {
+		assert.NoError(t, h(context.Background(), e))
+	}
+
+	assert.Len(t, handled, 2)
+	assert.Equal(t, hi.Version(1), handled[0].Version)
+	assert.Equal(t, hi.Version(3), handled[1].Version)
+	assert.Equal(t, 3, injector.Calls(OpPublish))
+}
+
+// region mocks
+
+type chaosEvent struct{}
+
+func events(aggregateID string, from hi.Version, count int) []hi.Event {
+	events := make([]hi.Event, count)
+	for i := range events {
+		events[i] = hi.Event{
+			AggregateID:   aggregateID,
+			AggregateType: "T",
+			Version:       from + hi.Version(i),
+			Data:          &chaosEvent{},
+		}
+	}
+	return events
+}
+
+// endregion
@@ -0,0 +1,67 @@
+package chaos
+
+import (
+	"context"
+
+	hi "github.com/bansukai/historia"
+)
+
+// NewEventStore wraps es, injecting the OpSaveEvents and OpGetEvents faults.
+// Only the EventStore methods are exposed, the optional capabilities of es are hidden.
+func NewEventStore(es hi.EventStore, faults ...Fault) *EventStore {
+	return &EventStore{
+		Injector: NewInjector(faults...),
+		store:    es,
+	}
+}
+
+// EventStore is an event store misbehaving according to its faults
+type EventStore struct {
+	*Injector
+	store hi.EventStore
+}
+
+func (e *EventStore) SaveEvents(ctx context.Context, events []hi.Event) error {
+	fault, ok := e.next(OpSaveEvents)
+	if !ok {
+		return e.store.SaveEvents(ctx, events)
+	}
+
+	if fault.PartialWrite > 0 && fault.PartialWrite < len(events) {
+		return e.partialWrite(ctx, fault, events)
+	}
+
+	if skip, err := fault.apply(ctx); skip {
+		return err
+	}
+	return e.store.SaveEvents(ctx, events)
+}
+
+func (e *EventStore) GetEvents(ctx context.Context, aggregateID string, aggregateType string, afterVersion hi.Version) ([]hi.Event, error) {
+	if fault, ok := e.next(OpGetEvents); ok {
+		if skip, err := fault.apply(ctx); skip {
+			return nil, err
+		}
+	}
+	return e.store.GetEvents(ctx, aggregateID, aggregateType, afterVersion)
+}
+
+func (e *EventStore) Close() error {
+	return e.store.Close()
+}
+
+// partialWrite saves the first events of the batch and fails
+func (e *EventStore) partialWrite(ctx context.Context, fault Fault, events []hi.Event) error {
+	if err := fault.wait(ctx); err != nil {
+		return err
+	}
+
+	if err := e.store.SaveEvents(ctx, events[:fault.PartialWrite]); err != nil {
+		return err
+	}
+
+	if fault.Err != nil {
+		return fault.Err
+	}
+	return ErrInjected
+}
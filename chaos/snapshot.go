@@ -0,0 +1,39 @@
+package chaos
+
+import (
+	"context"
+
+	hi "github.com/bansukai/historia"
+)
+
+// NewSnapshotStore wraps ss, injecting the OpSaveSnapshot and OpGetSnapshot faults
+func NewSnapshotStore(ss hi.SnapshotStore, faults ...Fault) *SnapshotStore {
+	return &SnapshotStore{
+		Injector: NewInjector(faults...),
+		store:    ss,
+	}
+}
+
+// SnapshotStore is a snapshot store misbehaving according to its faults
+type SnapshotStore struct {
+	*Injector
+	store hi.SnapshotStore
+}
+
+func (s *SnapshotStore) Get(ctx context.Context, aggregateID string, aggregateType string) (*hi.Snapshot, error) {
+	if fault, ok := s.next(OpGetSnapshot); ok {
+		if skip, err := fault.apply(ctx); skip {
+			return nil, err
+		}
+	}
+	return s.store.Get(ctx, aggregateID, aggregateType)
+}
+
+func (s *SnapshotStore) Save(ctx context.Context, ss *hi.Snapshot) error {
+	if fault, ok := s.next(OpSaveSnapshot); ok {
+		if skip, err := fault.apply(ctx); skip {
+			return err
+		}
+	}
+	return s.store.Save(ctx, ss)
+}
@@ -0,0 +1,33 @@
+package historia
+
+import (
+	"reflect"
+)
+
+// Cloner must be implemented by aggregates holding state behind pointers, in maps or slices that is changed in place
+// by their transitions, for unsaved changes to be rolled back.
+// Clone must return a copy of the aggregate, of the same type, sharing none of that state with it.
+// Aggregates that don't implement it are copied shallowly, keeping their dependencies as they are.
+type Cloner interface {
+	Clone() Aggregate
+}
+
+// cloneAggregate returns a copy of the struct the aggregate points to, made by Clone when the aggregate is a Cloner.
+// Otherwise the copy is shallow: state held behind pointers, in maps or slices is shared with the aggregate.
+func cloneAggregate(aggregate Aggregate) (reflect.Value, bool) {
+	v := reflect.ValueOf(aggregate)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	if c, ok := aggregate.(Cloner); ok {
+		cp := reflect.ValueOf(c.Clone())
+		if cp.Type() == v.Type() && !cp.IsNil() && cp.Pointer() != v.Pointer() {
+			return cp.Elem(), true
+		}
+	}
+
+	cp := reflect.New(v.Elem().Type()).Elem()
+	cp.Set(v.Elem())
+	return cp, true
+}
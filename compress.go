@@ -0,0 +1,138 @@
+package historia
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+const (
+	// compressionMagic starts the header of every payload written by CompressMarshal,
+	// it is followed by a byte naming the compression of the payload
+	compressionMagic = "\x00hz"
+
+	// compressionHeaderNone marks a payload that was stored without compression
+	compressionHeaderNone byte = 0x00
+
+	// compressionHeaderGzip marks a payload compressed with gzip
+	compressionHeaderGzip byte = 0x01
+
+	// DefaultCompressionThreshold is the payload size in bytes below which compression is skipped
+	DefaultCompressionThreshold = 256
+)
+
+// ErrUnknownCompressionHeader returned when a payload carries a compression header that is not supported
+var ErrUnknownCompressionHeader = errors.New("unknown compression header")
+
+// CompressOption configures a CompressMarshal
+type CompressOption func(c *CompressMarshal)
+
+// WithCompressionThreshold sets the payload size in bytes below which compression is skipped
+func WithCompressionThreshold(size int) CompressOption {
+	return func(c *CompressMarshal) {
+		c.threshold = size
+	}
+}
+
+// WithCompressionLevel sets the gzip compression level, see the compress/gzip package for valid values
+func WithCompressionLevel(level int) CompressOption {
+	return func(c *CompressMarshal) {
+		c.level = level
+	}
+}
+
+// NewCompressMarshal wraps a Marshaller compressing the payloads it produces.
+// Every payload written is prefixed with a 4 byte header, a magic prefix and a byte telling if it was compressed or not.
+// Payloads without the magic prefix are handed to the wrapped Marshaller verbatim, whatever their encoding,
+// making it possible to read data stored before compression was enabled.
+func NewCompressMarshal(m Marshaller, opts ...CompressOption) *CompressMarshal {
+	c := &CompressMarshal{
+		marshaller: m,
+		threshold:  DefaultCompressionThreshold,
+		level:      gzip.DefaultCompression,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// CompressMarshal is a Marshaller decorator compressing payloads above a size threshold
+type CompressMarshal struct {
+	marshaller Marshaller
+	threshold  int
+	level      int
+}
+
+// Marshal encodes v with the wrapped Marshaller and compresses the result if it is large enough
+func (c *CompressMarshal) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.marshaller.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < c.threshold {
+		return append(compressionHeader(compressionHeaderNone), data...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(compressionHeader(compressionHeaderGzip))
+
+	zw, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decompresses data if needed and decodes it with the wrapped Marshaller
+func (c *CompressMarshal) Unmarshal(data []byte, v interface{}) error {
+	data, err := decompress(data)
+	if err != nil {
+		return err
+	}
+
+	return c.marshaller.Unmarshal(data, v)
+}
+
+// compressionHeader returns the header of a payload with the compression
+func compressionHeader(compression byte) []byte {
+	return append([]byte(compressionMagic), compression)
+}
+
+// decompress strips the compression header and inflates the payload.
+// Payloads that don't start with the magic prefix are returned untouched.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) <= len(compressionMagic) || !bytes.HasPrefix(data, []byte(compressionMagic)) {
+		return data, nil
+	}
+
+	payload := data[len(compressionMagic)+1:]
+	switch data[len(compressionMagic)] {
+	case compressionHeaderNone:
+		return payload, nil
+	case compressionHeaderGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		return io.ReadAll(zr)
+	}
+
+	// a header from a newer version we can't read
+	return nil, ErrUnknownCompressionHeader
+}
@@ -0,0 +1,147 @@
+package historia
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewCompressMarshal_should_return_instance(t *testing.T) {
+	m := NewJSONMarshal()
+	c := NewCompressMarshal(m)
+	assert.Equal(t, m, c.marshaller)
+	assert.Equal(t, DefaultCompressionThreshold, c.threshold)
+	assert.Equal(t, gzip.DefaultCompression, c.level)
+
+	c = NewCompressMarshal(m, WithCompressionThreshold(10), WithCompressionLevel(gzip.BestSpeed))
+	assert.Equal(t, 10, c.threshold)
+	assert.Equal(t, gzip.BestSpeed, c.level)
+}
+
+func Test_CompressMarshal_Marshal_should_skip_compression_below_threshold(t *testing.T) {
+	c := NewCompressMarshal(NewJSONMarshal())
+	d := marData1{A: 1, B: "small"}
+
+	buf, err := c.Marshal(d)
+	assert.NoError(t, err)
+
+	expected, _ := json.Marshal(d)
+	assert.Equal(t, compressionHeader(compressionHeaderNone), buf[:4])
+	assert.Equal(t, expected, buf[4:])
+}
+
+func Test_CompressMarshal_Marshal_should_compress_above_threshold(t *testing.T) {
+	c := NewCompressMarshal(NewJSONMarshal(), WithCompressionThreshold(10))
+	d := marData1{A: 1, B: strings.Repeat("large", 200)}
+
+	buf, err := c.Marshal(d)
+	assert.NoError(t, err)
+
+	raw, _ := json.Marshal(d)
+	assert.Equal(t, compressionHeader(compressionHeaderGzip), buf[:4])
+	assert.Less(t, len(buf), len(raw))
+}
+
+func Test_CompressMarshal_Marshal_should_return_marshal_error(t *testing.T) {
+	err := errors.New("nope")
+	m := &marshalMocker{
+		marshal: func(v interface{}) ([]byte, error) { return nil, err },
+	}
+
+	_, actual := NewCompressMarshal(m).Marshal(marData1{})
+	assert.ErrorIs(t, actual, err)
+}
+
+func Test_CompressMarshal_Unmarshal_should_round_trip(t *testing.T) {
+	tests := []struct {
+		title     string
+		threshold int
+	}{
+		{"uncompressed", 1 << 20},
+		{"compressed", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			c := NewCompressMarshal(NewJSONMarshal(), WithCompressionThreshold(test.threshold))
+			d := marData1{A: 123, B: strings.Repeat("asd", 100)}
+
+			buf, err := c.Marshal(d)
+			assert.NoError(t, err)
+
+			var actual marData1
+			assert.NoError(t, c.Unmarshal(buf, &actual))
+			assert.Equal(t, d, actual)
+		})
+	}
+}
+
+func Test_CompressMarshal_Unmarshal_should_read_payloads_without_header(t *testing.T) {
+	c := NewCompressMarshal(NewJSONMarshal())
+	d := marData1{A: 123, B: "asd"}
+	buf, _ := json.Marshal(d)
+
+	var actual marData1
+	assert.NoError(t, c.Unmarshal(buf, &actual))
+	assert.Equal(t, d, actual)
+}
+
+func Test_CompressMarshal_Unmarshal_should_read_binary_payloads_without_header(t *testing.T) {
+	for _, payload := range [][]byte{{0x00, 0x01}, {0x01, 0x8b}, {0x05, 'h', 'z'}, {0x00, 'h', 'z'}} {
+		var actual []byte
+		m := &marshalMocker{
+			unmarshal: func(data []byte, v interface{}) error {
+				actual = data
+				return nil
+			},
+		}
+
+		assert.NoError(t, NewCompressMarshal(m).Unmarshal(payload, nil))
+		assert.Equal(t, payload, actual)
+	}
+}
+
+func Test_CompressMarshal_Unmarshal_should_return_error_on_unknown_header(t *testing.T) {
+	c := NewCompressMarshal(NewJSONMarshal())
+
+	var actual marData1
+	assert.ErrorIs(t, c.Unmarshal(append(compressionHeader(0x05), '{', '}'), &actual), ErrUnknownCompressionHeader)
+}
+
+func Test_CompressMarshal_should_work_with_Snapper(t *testing.T) {
+	var stored *Snapshot
+	ss := &snapStoreMocker{
+		save: func(ctx context.Context, s *Snapshot) error {
+			stored = s
+			return nil
+		},
+	}
+
+	agg := &ssAggWithSnapshot{
+		AggregateBase: AggregateBase{id: "yes", version: 3},
+		takeSnapshot:  func() SnapshotBody { return marData1{A: 1, B: strings.Repeat("state", 200)} },
+	}
+
+	s := NewSnapper(ss, NewCompressMarshal(NewJSONMarshal()))
+	assert.NoError(t, s.SaveSnapshot(context.Background(), agg))
+	assert.Equal(t, compressionHeader(compressionHeaderGzip), stored.State[:4])
+
+	ss.get = func(ctx context.Context, aggregateID, t string) (*Snapshot, error) { return stored, nil }
+
+	var applied SnapshotBody
+	agg.applySnapshot = func(state SnapshotBody) error {
+		applied = state
+		return nil
+	}
+
+	assert.NoError(t, s.ApplySnapshot(context.Background(), "yes", agg))
+	assert.Equal(t, Version(3), agg.Version())
+
+	body := *applied.(*SnapshotBody)
+	assert.Equal(t, strings.Repeat("state", 200), body.(map[string]interface{})["B"])
+}
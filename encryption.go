@@ -0,0 +1,488 @@
+package historia
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const (
+	// tagName is the struct tag used to mark event data fields
+	tagName = "historia"
+
+	// tagEncrypt marks a string field to be encrypted with the subject key
+	tagEncrypt = "encrypt"
+
+	// tagSubject marks the field holding the id of the subject owning the personal data
+	tagSubject = "subject"
+
+	// encryptedFieldPrefix prefixes encrypted string fields so they can be told apart from plain text
+	encryptedFieldPrefix = "enc:"
+
+	// DefaultRedactedValue is what encrypted fields read back as once the subject key is deleted
+	DefaultRedactedValue = "[redacted]"
+)
+
+var (
+	// ErrKeyNotFound returned by a KeyStore when the subject has no key
+	ErrKeyNotFound = errors.New("encryption key not found")
+
+	// ErrKeyShredded returned by KeyStore.Create when the key of the subject has been deleted
+	ErrKeyShredded = errors.New("encryption key shredded")
+
+	// ErrUnsupportedEncryptedField when a field tagged for encryption isn't an exported string
+	ErrUnsupportedEncryptedField = errors.New("only exported string fields can be encrypted")
+
+	// ErrMalformedCiphertext when an encrypted value is too short to be valid
+	ErrMalformedCiphertext = errors.New("malformed ciphertext")
+
+	// ErrDecryptionFailed when a ciphertext fails authentication with the key it is opened with
+	ErrDecryptionFailed = errors.New("decryption failed")
+)
+
+// KeyStore holds the per subject encryption keys.
+// Deleting the key of a subject makes all data encrypted for it unreadable (crypto-shredding).
+// Shredding is permanent, a deleted key is never created again.
+type KeyStore interface {
+	// Get returns the key of a subject, ErrKeyNotFound if there is none or it has been deleted.
+	Get(ctx context.Context, subjectID string) ([]byte, error)
+
+	// Create returns the key of a subject, generating a new 32 byte key if there is none.
+	// ErrKeyShredded is returned when the key of the subject has been deleted.
+	Create(ctx context.Context, subjectID string) ([]byte, error)
+
+	// Delete removes the key of a subject for good.
+	Delete(ctx context.Context, subjectID string) error
+}
+
+// EncryptedPayload replaces the Data of events whose payload is encrypted as a whole
+type EncryptedPayload struct {
+	Subject    string
+	Name       string
+	Ciphertext []byte
+}
+
+type EncryptionOption func(s *EncryptedEventStore)
+
+// WithPayloadEncryption marks event data types to be encrypted as a whole instead of field by field.
+// The types must be registered in the EventRegistry used by the store.
+func WithPayloadEncryption(data ...EventData) EncryptionOption {
+	return func(s *EncryptedEventStore) {
+		for i := range data {
+			s.payloads[reflect.TypeOf(data[i])] = struct{}{}
+		}
+	}
+}
+
+// WithEncryptionRegistry sets the EventRegistry used to recreate encrypted payloads, defaults to DefaultRegistry
+func WithEncryptionRegistry(registry EventRegistry) EncryptionOption {
+	return func(s *EncryptedEventStore) {
+		s.registry = registry
+	}
+}
+
+// WithEncryptionMarshaller sets the Marshaller used to encode encrypted payloads, defaults to JSON
+func WithEncryptionMarshaller(m Marshaller) EncryptionOption {
+	return func(s *EncryptedEventStore) {
+		s.marshaller = m
+	}
+}
+
+// WithRedactedValue sets what encrypted fields read back as once the subject key is deleted
+func WithRedactedValue(value string) EncryptionOption {
+	return func(s *EncryptedEventStore) {
+		s.redacted = value
+	}
+}
+
+// NewEncryptedEventStore wraps an EventStore encrypting personal data in the event data before it is stored.
+//
+// String fields tagged with `historia:"encrypt"` are encrypted with the key of the subject,
+// which is the value of the field tagged `historia:"subject"` or the aggregate ID when there is none.
+// Event data types passed to WithPayloadEncryption are encrypted as a whole.
+// Personal data of subjects whose key has been deleted is stored already redacted.
+// The AtomicEventStore, StreamDeleter and EventLogReader capabilities of es are forwarded.
+func NewEncryptedEventStore(es EventStore, ks KeyStore, opts ...EncryptionOption) *EncryptedEventStore {
+	s := &EncryptedEventStore{
+		store:      es,
+		keys:       ks,
+		registry:   DefaultRegistry,
+		marshaller: NewJSONMarshal(),
+		redacted:   DefaultRedactedValue,
+		payloads:   map[reflect.Type]struct{}{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// EncryptedEventStore is an EventStore decorator encrypting and decrypting event data
+type EncryptedEventStore struct {
+	store      EventStore
+	keys       KeyStore
+	registry   EventRegistry
+	marshaller Marshaller
+	redacted   string
+	payloads   map[reflect.Type]struct{}
+}
+
+// SaveEvents encrypts the events data and saves them in the underlying store
+func (s *EncryptedEventStore) SaveEvents(ctx context.Context, events []Event) error {
+	encrypted, err := s.encryptEvents(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	return s.store.SaveEvents(ctx, encrypted)
+}
+
+// SaveEventStreams encrypts the events data and saves the streams atomically in the underlying store,
+// failing with ErrAtomicSaveNotSupported when it isn't an AtomicEventStore
+func (s *EncryptedEventStore) SaveEventStreams(ctx context.Context, streams [][]Event) error {
+	encrypted := make([][]Event, len(streams))
+	for i := range streams {
+		events, err := s.encryptEvents(ctx, streams[i])
+		if err != nil {
+			return err
+		}
+		encrypted[i] = events
+	}
+
+	return saveEventStreams(ctx, s.store, encrypted)
+}
+
+// GetEvents fetches the events from the underlying store and decrypts their data.
+// Data belonging to subjects whose key has been deleted is redacted,
+// data failing to decrypt with the key of its subject returns ErrDecryptionFailed.
+func (s *EncryptedEventStore) GetEvents(ctx context.Context, aggregateID string, aggregateType string, afterVersion Version) ([]Event, error) {
+	events, err := s.store.GetEvents(ctx, aggregateID, aggregateType, afterVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decryptEvents(ctx, events)
+}
+
+// ReadAll reads the events of all the aggregates from the underlying store and decrypts their data as GetEvents does,
+// failing with ErrEventLogNotSupported when it isn't an EventLogReader
+func (s *EncryptedEventStore) ReadAll(ctx context.Context) ([]Event, error) {
+	log, ok := s.store.(EventLogReader)
+	if !ok {
+		return nil, ErrEventLogNotSupported
+	}
+
+	events, err := log.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decryptEvents(ctx, events)
+}
+
+// SoftDeleteStream soft deletes the stream in the underlying store
+func (s *EncryptedEventStore) SoftDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	return streamDeleterOf(s.store).SoftDeleteStream(ctx, aggregateID, aggregateType)
+}
+
+// HardDeleteStream hard deletes the stream in the underlying store
+func (s *EncryptedEventStore) HardDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	return streamDeleterOf(s.store).HardDeleteStream(ctx, aggregateID, aggregateType)
+}
+
+// TruncateStream truncates the stream in the underlying store
+func (s *EncryptedEventStore) TruncateStream(ctx context.Context, aggregateID string, aggregateType string, beforeVersion Version) error {
+	return streamDeleterOf(s.store).TruncateStream(ctx, aggregateID, aggregateType, beforeVersion)
+}
+
+// Unwrap returns the underlying store, for repositories to use its StreamInfoReader capability
+func (s *EncryptedEventStore) Unwrap() EventStore {
+	return s.store
+}
+
+// Close closes the underlying store
+func (s *EncryptedEventStore) Close() error {
+	return s.store.Close()
+}
+
+func (s *EncryptedEventStore) encryptEvents(ctx context.Context, events []Event) ([]Event, error) {
+	encrypted := make([]Event, len(events))
+	for i := range events {
+		event := events[i]
+
+		data, err := s.encrypt(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Data = data
+		encrypted[i] = event
+	}
+
+	return encrypted, nil
+}
+
+func (s *EncryptedEventStore) decryptEvents(ctx context.Context, events []Event) ([]Event, error) {
+	decrypted := make([]Event, len(events))
+	for i := range events {
+		event := events[i]
+
+		data, err := s.decrypt(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Data = data
+		decrypted[i] = event
+	}
+
+	return decrypted, nil
+}
+
+func (s *EncryptedEventStore) encrypt(ctx context.Context, event Event) (EventData, error) {
+	if event.Data == nil {
+		return nil, nil
+	}
+
+	if _, ok := s.payloads[reflect.TypeOf(event.Data)]; ok {
+		return s.encryptPayload(ctx, event)
+	}
+
+	return s.transformFields(event, func(subject, value string) (string, error) {
+		key, err := s.keys.Create(ctx, subject)
+		if errors.Is(err, ErrKeyShredded) {
+			// the subject has been forgotten, its data must not be stored readable
+			return s.redacted, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		ciphertext, err := seal(key, []byte(value))
+		if err != nil {
+			return "", err
+		}
+
+		return encryptedFieldPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+	})
+}
+
+func (s *EncryptedEventStore) decrypt(ctx context.Context, event Event) (EventData, error) {
+	if payload, ok := event.Data.(*EncryptedPayload); ok {
+		return s.decryptPayload(ctx, payload)
+	}
+
+	if event.Data == nil {
+		return nil, nil
+	}
+
+	return s.transformFields(event, func(subject, value string) (string, error) {
+		if !strings.HasPrefix(value, encryptedFieldPrefix) {
+			return value, nil
+		}
+
+		key, err := s.keys.Get(ctx, subject)
+		if errors.Is(err, ErrKeyNotFound) {
+			return s.redacted, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedFieldPrefix))
+		if err != nil {
+			return "", err
+		}
+
+		plaintext, err := open(key, ciphertext)
+		if err != nil {
+			return "", err
+		}
+
+		return string(plaintext), nil
+	})
+}
+
+func (s *EncryptedEventStore) encryptPayload(ctx context.Context, event Event) (EventData, error) {
+	subject := subjectOf(event)
+	payload := &EncryptedPayload{
+		Subject: subject,
+		Name:    s.registry.GetName(event.Data),
+	}
+
+	key, err := s.keys.Create(ctx, subject)
+	if errors.Is(err, ErrKeyShredded) {
+		// the subject has been forgotten, store the payload without its content
+		return payload, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := s.marshaller.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Ciphertext, err = seal(key, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func (s *EncryptedEventStore) decryptPayload(ctx context.Context, payload *EncryptedPayload) (EventData, error) {
+	data, err := s.registry.Create(payload.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.keys.Get(ctx, payload.Subject)
+	if errors.Is(err, ErrKeyNotFound) || len(payload.Ciphertext) == 0 {
+		// the subject has been forgotten, hand back the empty event data
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := open(key, payload.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.marshaller.Unmarshal(plaintext, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// transformFields returns a copy of the event data where fields tagged for encryption have been passed through fn
+func (s *EncryptedEventStore) transformFields(event Event, fn func(subject, value string) (string, error)) (EventData, error) {
+	rv := reflect.ValueOf(event.Data)
+	isPtr := rv.Kind() == reflect.Ptr
+	if isPtr {
+		if rv.IsNil() {
+			return event.Data, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return event.Data, nil
+	}
+
+	fields := encryptedFields(rv.Type())
+	if len(fields) == 0 {
+		return event.Data, nil
+	}
+
+	cp := reflect.New(rv.Type()).Elem()
+	cp.Set(rv)
+
+	subject := subjectOf(event)
+	for _, i := range fields {
+		field := cp.Field(i)
+		if field.Kind() != reflect.String || !field.CanSet() {
+			return nil, fmt.Errorf("%w: %s.%s", ErrUnsupportedEncryptedField, rv.Type().Name(), rv.Type().Field(i).Name)
+		}
+
+		value, err := fn(subject, field.String())
+		if err != nil {
+			return nil, err
+		}
+		field.SetString(value)
+	}
+
+	if isPtr {
+		return cp.Addr().Interface(), nil
+	}
+	return cp.Interface(), nil
+}
+
+// encryptedFields returns the index of the fields tagged for encryption
+func encryptedFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(tagName) == tagEncrypt {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// subjectOf returns the value of the field tagged as subject, or the aggregate ID if there is none
+func subjectOf(event Event) string {
+	rv := reflect.ValueOf(event.Data)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct {
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get(tagName) != tagSubject {
+				continue
+			}
+			if subject := rv.Field(i); subject.Kind() == reflect.String && subject.String() != "" {
+				return subject.String()
+			}
+		}
+	}
+
+	return event.AggregateID
+}
+
+// seal encrypts plaintext with AES-GCM prefixing the result with the nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a ciphertext produced by seal
+func open(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,341 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewEncryptedEventStore_should_return_valid_instance(t *testing.T) {
+	es := &eventStoreMocker{}
+	ks := newKeyStoreMocker()
+	s := NewEncryptedEventStore(es, ks)
+
+	assert.Equal(t, es, s.store)
+	assert.Equal(t, ks, s.keys)
+	assert.Equal(t, DefaultRegistry, s.registry)
+	assert.Equal(t, DefaultRedactedValue, s.redacted)
+	assert.Empty(t, s.payloads)
+
+	registry := NewEventRegistry()
+	s = NewEncryptedEventStore(es, ks, WithEncryptionRegistry(registry), WithRedactedValue("gone"), WithPayloadEncryption(&encPayload{}))
+	assert.Equal(t, registry, s.registry)
+	assert.Equal(t, "gone", s.redacted)
+	assert.Len(t, s.payloads, 1)
+}
+
+func Test_EncryptedEventStore_SaveEvents_should_encrypt_tagged_fields(t *testing.T) {
+	var saved []Event
+	es := &eventStoreMocker{
+		save: func(ctx context.Context, events []Event) error {
+			saved = events
+			return nil
+		},
+	}
+
+	s := NewEncryptedEventStore(es, newKeyStoreMocker())
+	data := &encPersonRegistered{UserID: "user-1", Name: "Jane", Email: "jane@example.com", Plan: "gold"}
+	assert.NoError(t, s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: data}}))
+
+	actual := saved[0].Data.(*encPersonRegistered)
+	assert.Equal(t, "user-1", actual.UserID)
+	assert.Equal(t, "gold", actual.Plan)
+	assert.True(t, strings.HasPrefix(actual.Name, encryptedFieldPrefix))
+	assert.True(t, strings.HasPrefix(actual.Email, encryptedFieldPrefix))
+
+	// the callers event data must be left untouched
+	assert.Equal(t, "Jane", data.Name)
+}
+
+func Test_EncryptedEventStore_should_round_trip_fields(t *testing.T) {
+	s := NewEncryptedEventStore(newEventStoreRecorder(), newKeyStoreMocker())
+	data := &encPersonRegistered{UserID: "user-1", Name: "Jane", Email: "jane@example.com"}
+	assert.NoError(t, s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: data}}))
+
+	events, err := s.GetEvents(context.Background(), "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, data, events[0].Data)
+}
+
+func Test_EncryptedEventStore_GetEvents_should_redact_fields_when_key_is_deleted(t *testing.T) {
+	ks := newKeyStoreMocker()
+	s := NewEncryptedEventStore(newEventStoreRecorder(), ks)
+	data := &encPersonRegistered{UserID: "user-1", Name: "Jane", Email: "jane@example.com", Plan: "gold"}
+	assert.NoError(t, s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: data}}))
+
+	assert.NoError(t, ks.Delete(context.Background(), "user-1"))
+
+	events, err := s.GetEvents(context.Background(), "agg", "", 0)
+	assert.NoError(t, err)
+
+	actual := events[0].Data.(*encPersonRegistered)
+	assert.Equal(t, DefaultRedactedValue, actual.Name)
+	assert.Equal(t, DefaultRedactedValue, actual.Email)
+	assert.Equal(t, "gold", actual.Plan)
+}
+
+func Test_EncryptedEventStore_should_use_aggregate_id_as_subject_when_not_tagged(t *testing.T) {
+	ks := newKeyStoreMocker()
+	s := NewEncryptedEventStore(newEventStoreRecorder(), ks)
+	assert.NoError(t, s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: &encNoSubject{Secret: "shh"}}}))
+
+	_, err := ks.Get(context.Background(), "agg")
+	assert.NoError(t, err)
+}
+
+func Test_EncryptedEventStore_SaveEvents_should_reject_non_string_fields(t *testing.T) {
+	s := NewEncryptedEventStore(newEventStoreRecorder(), newKeyStoreMocker())
+	err := s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: &encBadField{Age: 12}}})
+	assert.ErrorIs(t, err, ErrUnsupportedEncryptedField)
+}
+
+func Test_EncryptedEventStore_SaveEvents_should_return_key_store_error(t *testing.T) {
+	err := errors.New("no keys today")
+	ks := newKeyStoreMocker()
+	ks.err = err
+
+	s := NewEncryptedEventStore(newEventStoreRecorder(), ks)
+	actual := s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: &encNoSubject{Secret: "shh"}}})
+	assert.ErrorIs(t, actual, err)
+}
+
+func Test_EncryptedEventStore_should_encrypt_whole_payload(t *testing.T) {
+	registry := NewEventRegistry()
+	assert.NoError(t, registry.Register(func() EventData { return &encPayload{} }))
+
+	es := newEventStoreRecorder()
+	ks := newKeyStoreMocker()
+	s := NewEncryptedEventStore(es, ks, WithEncryptionRegistry(registry), WithPayloadEncryption(&encPayload{}))
+
+	data := &encPayload{Address: "Main street 1"}
+	assert.NoError(t, s.SaveEvents(context.Background(), []Event{{AggregateID: "agg", Version: 1, Data: data}}))
+
+	stored := es.events[0].Data.(*EncryptedPayload)
+	assert.Equal(t, "agg", stored.Subject)
+	assert.Equal(t, registry.GetName(data), stored.Name)
+	assert.NotContains(t, string(stored.Ciphertext), "Main street")
+
+	events, err := s.GetEvents(context.Background(), "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, data, events[0].Data)
+
+	assert.NoError(t, ks.Delete(context.Background(), "agg"))
+	events, err = s.GetEvents(context.Background(), "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &encPayload{}, events[0].Data)
+}
+
+func Test_EncryptedEventStore_should_let_Repo_Get_succeed_after_shredding(t *testing.T) {
+	ks := newKeyStoreMocker()
+	repo := NewRepository(NewEncryptedEventStore(newEventStoreRecorder(), ks), nil)
+
+	p := encAggregate{}
+	_ = p.SetID("agg")
+	p.TrackChange(&p, &encPersonRegistered{UserID: "user-1", Name: "Jane"})
+	assert.NoError(t, repo.Save(context.Background(), &p))
+
+	assert.NoError(t, ks.Delete(context.Background(), "user-1"))
+
+	p1 := encAggregate{}
+	assert.NoError(t, repo.Get(context.Background(), "agg", &p1))
+	assert.Equal(t, DefaultRedactedValue, p1.name)
+}
+
+func Test_EncryptedEventStore_should_keep_data_redacted_when_saving_after_shredding(t *testing.T) {
+	ctx := context.Background()
+	ks := newKeyStoreMocker()
+	es := newEventStoreRecorder()
+	s := NewEncryptedEventStore(es, ks)
+
+	assert.NoError(t, s.SaveEvents(ctx, []Event{{AggregateID: "agg", Version: 1, Data: &encPersonRegistered{UserID: "user-1", Name: "Jane"}}}))
+	assert.NoError(t, ks.Delete(ctx, "user-1"))
+
+	events, err := s.GetEvents(ctx, "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRedactedValue, events[0].Data.(*encPersonRegistered).Name)
+
+	assert.NoError(t, s.SaveEvents(ctx, []Event{{AggregateID: "agg", Version: 2, Data: &encPersonRegistered{UserID: "user-1", Name: "Jane"}}}))
+	assert.Equal(t, DefaultRedactedValue, es.events[1].Data.(*encPersonRegistered).Name)
+
+	events, err = s.GetEvents(ctx, "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, DefaultRedactedValue, events[0].Data.(*encPersonRegistered).Name)
+	assert.Equal(t, DefaultRedactedValue, events[1].Data.(*encPersonRegistered).Name)
+}
+
+func Test_EncryptedEventStore_should_keep_payload_empty_when_saving_after_shredding(t *testing.T) {
+	ctx := context.Background()
+	registry := NewEventRegistry()
+	assert.NoError(t, registry.Register(func() EventData { return &encPayload{} }))
+
+	ks := newKeyStoreMocker()
+	es := newEventStoreRecorder()
+	s := NewEncryptedEventStore(es, ks, WithEncryptionRegistry(registry), WithPayloadEncryption(&encPayload{}))
+	assert.NoError(t, ks.Delete(ctx, "agg"))
+
+	assert.NoError(t, s.SaveEvents(ctx, []Event{{AggregateID: "agg", Version: 1, Data: &encPayload{Address: "Main street 1"}}}))
+	assert.Empty(t, es.events[0].Data.(*EncryptedPayload).Ciphertext)
+
+	events, err := s.GetEvents(ctx, "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &encPayload{}, events[0].Data)
+}
+
+func Test_EncryptedEventStore_GetEvents_should_fail_on_data_encrypted_with_another_key(t *testing.T) {
+	ctx := context.Background()
+	registry := NewEventRegistry()
+	assert.NoError(t, registry.Register(func() EventData { return &encPayload{} }))
+
+	ks := newKeyStoreMocker()
+	s := NewEncryptedEventStore(newEventStoreRecorder(), ks, WithEncryptionRegistry(registry), WithPayloadEncryption(&encPayload{}))
+	assert.NoError(t, s.SaveEvents(ctx, []Event{
+		{AggregateID: "agg", Version: 1, Data: &encPersonRegistered{UserID: "user-1", Name: "Jane"}},
+		{AggregateID: "agg", Version: 2, Data: &encPayload{Address: "Main street 1"}},
+	}))
+
+	ks.keys["user-1"] = []byte(strings.Repeat("x", 32))
+	ks.keys["agg"] = []byte(strings.Repeat("x", 32))
+
+	_, err := s.GetEvents(ctx, "agg", "", 0)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	delete(ks.keys, "user-1")
+	_, err = s.GetEvents(ctx, "agg", "", 0)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	delete(ks.keys, "agg")
+	events, err := s.GetEvents(ctx, "agg", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRedactedValue, events[0].Data.(*encPersonRegistered).Name)
+	assert.Equal(t, &encPayload{}, events[1].Data)
+}
+
+func Test_EncryptedEventStore_should_not_support_capabilities_the_wrapped_store_lacks(t *testing.T) {
+	ctx := context.Background()
+	s := NewEncryptedEventStore(newEventStoreRecorder(), newKeyStoreMocker())
+
+	assert.ErrorIs(t, s.SaveEventStreams(ctx, [][]Event{{{AggregateID: "agg", Version: 1}}}), ErrAtomicSaveNotSupported)
+	assert.ErrorIs(t, s.SoftDeleteStream(ctx, "agg", ""), ErrDeleteNotSupported)
+	_, err := s.ReadAll(ctx)
+	assert.ErrorIs(t, err, ErrEventLogNotSupported)
+}
+
+func Test_seal_open(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	ciphertext, err := seal(key, []byte("hello"))
+	assert.NoError(t, err)
+
+	plaintext, err := open(key, ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+
+	_, err = open(key, []byte{1})
+	assert.ErrorIs(t, err, ErrMalformedCiphertext)
+
+	_, err = open([]byte(strings.Repeat("x", 32)), ciphertext)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
+
+// region mocks
+
+type encPersonRegistered struct {
+	UserID string `historia:"subject"`
+	Name   string `historia:"encrypt"`
+	Email  string `historia:"encrypt"`
+	Plan   string
+}
+
+type encNoSubject struct {
+	Secret string `historia:"encrypt"`
+}
+
+type encBadField struct {
+	Age int `historia:"encrypt"`
+}
+
+type encPayload struct {
+	Address string
+}
+
+type encAggregate struct {
+	AggregateBase
+	name string
+}
+
+func (e *encAggregate) Transition(evt Event) {
+	if d, ok := evt.Data.(*encPersonRegistered); ok {
+		e.name = d.Name
+	}
+}
+
+type keyStoreMocker struct {
+	keys     map[string][]byte
+	shredded map[string]bool
+	err      error
+}
+
+func newKeyStoreMocker() *keyStoreMocker {
+	return &keyStoreMocker{keys: map[string][]byte{}, shredded: map[string]bool{}}
+}
+
+func (k *keyStoreMocker) Get(_ context.Context, subjectID string) ([]byte, error) {
+	key, ok := k.keys[subjectID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (k *keyStoreMocker) Create(_ context.Context, subjectID string) ([]byte, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+	if k.shredded[subjectID] {
+		return nil, ErrKeyShredded
+	}
+	if _, ok := k.keys[subjectID]; !ok {
+		k.keys[subjectID] = []byte(strings.Repeat(subjectID[:1], 32))
+	}
+	return k.keys[subjectID], nil
+}
+
+func (k *keyStoreMocker) Delete(_ context.Context, subjectID string) error {
+	delete(k.keys, subjectID)
+	k.shredded[subjectID] = true
+	return nil
+}
+
+type eventStoreRecorder struct {
+	events []Event
+}
+
+func newEventStoreRecorder() *eventStoreRecorder {
+	return &eventStoreRecorder{}
+}
+
+func (e *eventStoreRecorder) SaveEvents(_ context.Context, events []Event) error {
+	e.events = append(e.events, events...)
+	return nil
+}
+
+func (e *eventStoreRecorder) GetEvents(_ context.Context, aggregateID string, _ string, afterVersion Version) ([]Event, error) {
+	var events []Event
+	for i := range e.events {
+		if e.events[i].AggregateID == aggregateID && e.events[i].Version > afterVersion {
+			events = append(events, e.events[i])
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, ErrNoEvents
+	}
+	return events, nil
+}
+
+func (e *eventStoreRecorder) Close() error { return nil }
+
+// endregion
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 )
 
@@ -11,9 +12,16 @@ var (
 	ErrFactoryShouldReturnValidPointer = errors.New("the provided factory didn't return a valid pointer")
 	ErrEventDataFactoryNotRegistered   = errors.New("event data factory not registered for this type")
 
-	DefaultRegistry = NewEventRegistry()
+	// DefaultRegistry is the registry used by the package level functions, the Tombstone is registered in it
+	DefaultRegistry = newDefaultRegistry()
 )
 
+func newDefaultRegistry() *EventRegister {
+	r := NewEventRegistry()
+	_ = r.Register(func() EventData { return &Tombstone{} })
+	return r
+}
+
 type EventRegistry interface {
 	// Register registers an event data factory for a type. The factory is
 	// used to create concrete event data structs when loading from a database.
@@ -117,6 +125,20 @@ func (e *EventRegister) Create(name string) (EventData, error) {
 	return nil, ErrEventDataFactoryNotRegistered
 }
 
+// Names returns the sorted names of the registered event data types
+func (e *EventRegister) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.factories))
+	for name := range e.factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
 func (e *EventRegister) GetName(data EventData) string {
 	return e.eventDataNameFormatter(data)
 }
@@ -19,6 +19,12 @@ func Test_NewEventRegistry_should_return_valid_instance(t *testing.T) {
 	assert.Equal(t, reflect.ValueOf(fn).Pointer(), reflect.ValueOf(registry.eventDataNameFormatter).Pointer())
 }
 
+func Test_DefaultRegistry_should_register_Tombstone(t *testing.T) {
+	data, err := DefaultRegistry.Create(DefaultRegistry.GetName(&Tombstone{}))
+	assert.NoError(t, err)
+	assert.IsType(t, &Tombstone{}, data)
+}
+
 func Test_EventRegistry_Register(t *testing.T) {
 	t.Run("factory should return pointer", func(t *testing.T) {
 		registry := NewEventRegistry()
@@ -80,6 +86,17 @@ func Test_EventRegistry_Create(t *testing.T) {
 	})
 }
 
+func Test_EventRegistry_Names(t *testing.T) {
+	registry := NewEventRegistry(WithEventDataNameFormatter(func(data EventData) string {
+		return reflect.TypeOf(data).Elem().Name()
+	}))
+	assert.Empty(t, registry.Names())
+
+	_ = registry.Register(factoryFn)
+	_ = registry.Register(func() EventData { return &anotherRegistryTestData{} })
+	assert.Equal(t, []string{"anotherRegistryTestData", "eventRegistryTestData"}, registry.Names())
+}
+
 func Test_dn(t *testing.T) {
 	n1 := defaultNameFormatter(eventRegistryTestData{})
 	n2 := defaultNameFormatter(&eventRegistryTestData{})
@@ -92,6 +109,8 @@ func factoryBadFn() EventData { return eventRegistryTestData{} }
 func factoryNilFn() EventData { return nil }
 func factoryFn() EventData    { return &eventRegistryTestData{} }
 
+type anotherRegistryTestData struct{}
+
 type eventRegistryTestData struct {
 	Something string
 	Other     int
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,15 +21,40 @@ func AcceptanceTest(t *testing.T, eventStore hi.EventStore) {
 		run   func(es hi.EventStore) error
 	}{
 		{"should save and get events", saveAndGetEvents},
+		{"should reject version conflicts", versionConflict},
+		{"should let a single concurrent writer win", concurrentWriters},
+		{"should reject batches of several aggregates", multipleAggregates},
+		{"should reject events without reason", missingReason},
+		{"should get events after version", eventsAfterVersion},
+		{"should return ErrNoEvents for unknown streams", unknownStream},
+		{"should round trip metadata types", metadataTypes},
+		{"should keep timestamps to the microsecond", timestampPrecision},
+		{"should save large batches", largeBatch},
+		{"should stop on cancelled context", cancelledContext},
+		{"should describe stream", describeStream},
+		{"should soft delete stream", softDeleteStream},
+		{"should hard delete stream", hardDeleteStream},
+		{"should truncate stream", truncateStream},
+		{"should save streams atomically", saveEventStreams},
+		{"should return concurrency error", concurrencyError},
+		{"should ignore retried appends", idempotentAppend},
+		{"should read the event log in order", readAll},
 	}
 
 	for _, test := range tests {
 		t.Run(test.title, func(t *testing.T) {
-			assert.NoError(t, test.run(eventStore))
+			err := test.run(eventStore)
+			if errors.Is(err, errNotSupported) {
+				t.Skip(err)
+			}
+			assert.NoError(t, err)
 		})
 	}
 }
 
+// errNotSupported is returned by tests of optional capabilities the store doesn't implement
+var errNotSupported = errors.New("capability not supported by the store")
+
 //nolint:gocyclo // it's complicated
 func saveAndGetEvents(es hi.EventStore) error {
 	aggregateID := idFunc()
@@ -104,8 +131,679 @@ func saveAndGetEvents(es hi.EventStore) error {
 	return nil
 }
 
+// versionConflict describes saving events not following the last stored version:
+// saving the same versions twice or leaving a gap returns ErrConcurrency and saves nothing.
+func versionConflict(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); err != nil {
+		return err
+	}
+
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency saving versions twice, got %v", err)
+	}
+
+	gap := createEventsContinue(aggregateID)[1:]
+	if err := es.SaveEvents(ctx, gap); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency saving with a gap, got %v", err)
+	}
+
+	unordered := createEventsContinue(aggregateID)
+	unordered[0], unordered[1] = unordered[1], unordered[0]
+	if err := es.SaveEvents(ctx, unordered); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency saving unordered versions, got %v", err)
+	}
+
+	return expectEventCount(es, aggregateID, len(createEvents(aggregateID)))
+}
+
+// concurrentWriters describes writers racing to append the same versions to a stream,
+// exactly one of them succeeds and the others get ErrConcurrency.
+func concurrentWriters(es hi.EventStore) error {
+	const writers = 10
+
+	ctx := context.Background()
+	aggregateID := idFunc()
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	start := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			<-start
+			errs[w] = es.SaveEvents(ctx, createEventsContinue(aggregateID))
+		}(w)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrConcurrency):
+			return fmt.Errorf("expected ErrConcurrency for losing writers, got %v", err)
+		}
+	}
+
+	if succeeded != 1 {
+		return fmt.Errorf("expected a single writer to succeed, %d did", succeeded)
+	}
+
+	return expectEventCount(es, aggregateID, len(createEvents(aggregateID))+len(createEventsContinue(aggregateID)))
+}
+
+// multipleAggregates describes batches holding events of several aggregates, they are rejected as a whole
+func multipleAggregates(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+
+	events := createEvents(aggregateID)
+	events[3].AggregateID = idFunc()
+	if err := es.SaveEvents(ctx, events); !errors.Is(err, ErrEventMultipleAggregates) {
+		return fmt.Errorf("expected ErrEventMultipleAggregates, got %v", err)
+	}
+
+	events = createEvents(aggregateID)
+	events[3].AggregateType = aggregateType + "Other"
+	if err := es.SaveEvents(ctx, events); !errors.Is(err, ErrEventMultipleAggregateTypes) {
+		return fmt.Errorf("expected ErrEventMultipleAggregateTypes, got %v", err)
+	}
+
+	return expectEventCount(es, aggregateID, 0)
+}
+
+// missingReason describes events without data, they are rejected with their batch
+func missingReason(es hi.EventStore) error {
+	aggregateID := idFunc()
+	events := createEvents(aggregateID)
+	events[2].Data = nil
+
+	if err := es.SaveEvents(context.Background(), events); !errors.Is(err, ErrReasonMissing) {
+		return fmt.Errorf("expected ErrReasonMissing, got %v", err)
+	}
+
+	return expectEventCount(es, aggregateID, 0)
+}
+
+// eventsAfterVersion describes reading the events following a version, in order
+func eventsAfterVersion(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+	events := createEvents(aggregateID)
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return err
+	}
+
+	fetched, err := es.GetEvents(ctx, aggregateID, aggregateType, 3)
+	if err != nil {
+		return err
+	}
+
+	if len(fetched) != len(events)-3 {
+		return fmt.Errorf("expected %d events after version 3, got %d", len(events)-3, len(fetched))
+	}
+
+	for i := range fetched {
+		if fetched[i].Version != hi.Version(i+4) {
+			return fmt.Errorf("expected version %d, got %d", i+4, fetched[i].Version)
+		}
+	}
+
+	last := events[len(events)-1].Version
+	if _, err := es.GetEvents(ctx, aggregateID, aggregateType, last); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents after the last version, got %v", err)
+	}
+
+	return nil
+}
+
+// unknownStream describes reading streams without events, including the stream of
+// a known aggregate ID under another aggregate type.
+func unknownStream(es hi.EventStore) error {
+	ctx := context.Background()
+	if _, err := es.GetEvents(ctx, idFunc(), aggregateType, 0); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents, got %v", err)
+	}
+
+	aggregateID := idFunc()
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); err != nil {
+		return err
+	}
+
+	if _, err := es.GetEvents(ctx, aggregateID, aggregateType+"Other", 0); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents for another aggregate type, got %v", err)
+	}
+
+	return nil
+}
+
+// metadataTypes describes the metadata values stores round trip,
+// the JSON types: strings, float64 numbers, booleans, nil, slices and maps of them.
+func metadataTypes(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+
+	metadata := hi.EventMetadata{
+		"string": "hello",
+		"number": 12.5,
+		"bool":   true,
+		"nil":    nil,
+		"slice":  []interface{}{"a", 1.0, false},
+		"map":    map[string]interface{}{"nested": "value"},
+	}
+
+	events := createEvents(aggregateID)[:1]
+	events[0].Metadata = metadata
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return err
+	}
+
+	fetched, err := es.GetEvents(ctx, aggregateID, aggregateType, 0)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range metadata {
+		if !reflect.DeepEqual(fetched[0].Metadata[key], value) {
+			return fmt.Errorf("metadata %q: expected %#v, got %#v", key, value, fetched[0].Metadata[key])
+		}
+	}
+
+	return nil
+}
+
+// timestampPrecision describes the precision of the stored timestamps, at least the microsecond
+func timestampPrecision(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+
+	ts := time.Date(2021, 6, 15, 12, 30, 45, 123456000, time.UTC)
+	events := createEvents(aggregateID)[:1]
+	events[0].Timestamp = ts
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return err
+	}
+
+	fetched, err := es.GetEvents(ctx, aggregateID, aggregateType, 0)
+	if err != nil {
+		return err
+	}
+
+	if !fetched[0].Timestamp.Equal(ts) {
+		return fmt.Errorf("expected timestamp %s, got %s", ts, fetched[0].Timestamp)
+	}
+
+	return nil
+}
+
+// largeBatch describes saving many events at once
+func largeBatch(es hi.EventStore) error {
+	const size = 1000
+
+	ctx := context.Background()
+	aggregateID := idFunc()
+
+	events := make([]hi.Event, size)
+	for i := range events {
+		events[i] = hi.Event{
+			AggregateID:   aggregateID,
+			AggregateType: aggregateType,
+			Version:       hi.Version(i + 1),
+			Timestamp:     timestamp,
+			Data:          &eventTaken{ValueAdded: i, PointsAdded: 1},
+		}
+	}
+
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return err
+	}
+
+	fetched, err := es.GetEvents(ctx, aggregateID, aggregateType, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(fetched) != size {
+		return fmt.Errorf("expected %d events, got %d", size, len(fetched))
+	}
+
+	for i := range fetched {
+		data, ok := fetched[i].Data.(*eventTaken)
+		if !ok || data.ValueAdded != i || fetched[i].Version != hi.Version(i+1) {
+			return fmt.Errorf("wrong event at index %d", i)
+		}
+	}
+
+	return nil
+}
+
+// cancelledContext describes calls made with a cancelled context,
+// they return the context error and don't save anything.
+func cancelledContext(es hi.EventStore) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	aggregateID := idFunc()
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected context.Canceled saving, got %v", err)
+	}
+
+	if err := expectEventCount(es, aggregateID, 0); err != nil {
+		return err
+	}
+
+	if err := es.SaveEvents(context.Background(), createEvents(aggregateID)); err != nil {
+		return err
+	}
+
+	if _, err := es.GetEvents(ctx, aggregateID, aggregateType, 0); !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected context.Canceled getting, got %v", err)
+	}
+
+	return nil
+}
+
+// expectEventCount checks the number of events stored for the aggregate
+func expectEventCount(es hi.EventStore, aggregateID string, count int) error {
+	fetched, err := es.GetEvents(context.Background(), aggregateID, aggregateType, 0)
+	if count == 0 && errors.Is(err, hi.ErrNoEvents) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(fetched) != count {
+		return fmt.Errorf("expected %d stored events, got %d", count, len(fetched))
+	}
+	return nil
+}
+
+func describeStream(es hi.EventStore) error {
+	reader, ok := es.(hi.StreamInfoReader)
+	if !ok {
+		return errNotSupported
+	}
+
+	aggregateID := idFunc()
+	if _, err := reader.StreamInfo(context.Background(), aggregateID, aggregateType); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents for unknown stream, got %v", err)
+	}
+
+	events := createEvents(aggregateID)
+	events[0].Timestamp = timestamp.Add(-time.Hour)
+	if err := es.SaveEvents(context.Background(), events); err != nil {
+		return err
+	}
+
+	info, err := reader.StreamInfo(context.Background(), aggregateID, aggregateType)
+	if err != nil {
+		return err
+	}
+
+	if info.AggregateID != aggregateID || info.AggregateType != aggregateType {
+		return errors.New("wrong stream described")
+	}
+
+	if info.Version != events[len(events)-1].Version {
+		return fmt.Errorf("wrong version %d", info.Version)
+	}
+
+	if info.EventCount != len(events) {
+		return fmt.Errorf("wrong event count %d", info.EventCount)
+	}
+
+	if !info.FirstEventAt.Equal(events[0].Timestamp) || !info.LastEventAt.Equal(timestamp) {
+		return errors.New("wrong first or last event timestamp")
+	}
+
+	if info.Deleted {
+		return errors.New("stream should not be deleted")
+	}
+
+	return nil
+}
+
+// softDeleteStream describes the tombstone semantics:
+// soft deleting an unknown stream returns ErrNoEvents, deleting it twice returns ErrStreamDeleted,
+// once deleted the stream can't be read, written to nor truncated, and is described as deleted.
+//
+//nolint:gocyclo // it's a specification
+func softDeleteStream(es hi.EventStore) error {
+	deleter, ok := es.(hi.StreamDeleter)
+	if !ok {
+		return errNotSupported
+	}
+
+	ctx := context.Background()
+	aggregateID := idFunc()
+	if err := deleter.SoftDeleteStream(ctx, aggregateID, aggregateType); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents deleting unknown stream, got %v", err)
+	}
+
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); err != nil {
+		return err
+	}
+
+	if err := deleter.SoftDeleteStream(ctx, aggregateID, aggregateType); err != nil {
+		return err
+	}
+
+	if err := deleter.SoftDeleteStream(ctx, aggregateID, aggregateType); !errors.Is(err, hi.ErrStreamDeleted) {
+		return fmt.Errorf("expected ErrStreamDeleted deleting twice, got %v", err)
+	}
+
+	if _, err := es.GetEvents(ctx, aggregateID, aggregateType, 0); !errors.Is(err, hi.ErrStreamDeleted) {
+		return fmt.Errorf("expected ErrStreamDeleted reading deleted stream, got %v", err)
+	}
+
+	continued := createEventsContinue(aggregateID)
+	if err := es.SaveEvents(ctx, continued); !errors.Is(err, hi.ErrStreamDeleted) {
+		return fmt.Errorf("expected ErrStreamDeleted writing deleted stream, got %v", err)
+	}
+
+	if err := deleter.TruncateStream(ctx, aggregateID, aggregateType, 2); !errors.Is(err, hi.ErrStreamDeleted) {
+		return fmt.Errorf("expected ErrStreamDeleted truncating deleted stream, got %v", err)
+	}
+
+	if reader, ok := es.(hi.StreamInfoReader); ok {
+		info, err := reader.StreamInfo(ctx, aggregateID, aggregateType)
+		if err != nil {
+			return err
+		}
+		if !info.Deleted {
+			return errors.New("stream should be described as deleted")
+		}
+	}
+
+	return nil
+}
+
+// hardDeleteStream describes the hard delete semantics:
+// hard deleting an unknown stream returns ErrNoEvents, once deleted the stream is gone as if it never
+// existed and can be written to again from version 1, which also holds for soft deleted streams.
+func hardDeleteStream(es hi.EventStore) error {
+	deleter, ok := es.(hi.StreamDeleter)
+	if !ok {
+		return errNotSupported
+	}
+
+	ctx := context.Background()
+	aggregateID := idFunc()
+	if err := deleter.HardDeleteStream(ctx, aggregateID, aggregateType); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents deleting unknown stream, got %v", err)
+	}
+
+	for _, soft := range []bool{false, true} {
+		if err := es.SaveEvents(ctx, createEvents(aggregateID)); err != nil {
+			return err
+		}
+
+		if soft {
+			if err := deleter.SoftDeleteStream(ctx, aggregateID, aggregateType); err != nil {
+				return err
+			}
+		}
+
+		if err := deleter.HardDeleteStream(ctx, aggregateID, aggregateType); err != nil {
+			return err
+		}
+
+		if _, err := es.GetEvents(ctx, aggregateID, aggregateType, 0); !errors.Is(err, hi.ErrNoEvents) {
+			return fmt.Errorf("expected ErrNoEvents reading hard deleted stream, got %v", err)
+		}
+	}
+
+	return nil
+}
+
+// truncateStream describes the truncate semantics:
+// truncating an unknown stream returns ErrNoEvents and truncating beyond the last event ErrTruncateBeyondVersion.
+// Events with a version lower than beforeVersion are removed while the stream version is preserved,
+// so new events continue after the last one.
+//
+//nolint:gocyclo // it's a specification
+func truncateStream(es hi.EventStore) error {
+	deleter, ok := es.(hi.StreamDeleter)
+	if !ok {
+		return errNotSupported
+	}
+
+	ctx := context.Background()
+	aggregateID := idFunc()
+	if err := deleter.TruncateStream(ctx, aggregateID, aggregateType, 1); !errors.Is(err, hi.ErrNoEvents) {
+		return fmt.Errorf("expected ErrNoEvents truncating unknown stream, got %v", err)
+	}
+
+	events := createEvents(aggregateID)
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return err
+	}
+
+	last := events[len(events)-1].Version
+	if err := deleter.TruncateStream(ctx, aggregateID, aggregateType, last+1); !errors.Is(err, hi.ErrTruncateBeyondVersion) {
+		return fmt.Errorf("expected ErrTruncateBeyondVersion, got %v", err)
+	}
+
+	if err := deleter.TruncateStream(ctx, aggregateID, aggregateType, 4); err != nil {
+		return err
+	}
+
+	fetched, err := es.GetEvents(ctx, aggregateID, aggregateType, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(fetched) != int(last)-3 || fetched[0].Version != 4 {
+		return errors.New("wrong events kept after truncating")
+	}
+
+	if reader, ok := es.(hi.StreamInfoReader); ok {
+		info, err := reader.StreamInfo(ctx, aggregateID, aggregateType)
+		if err != nil {
+			return err
+		}
+		if info.Version != last || info.EventCount != len(fetched) {
+			return errors.New("truncated stream wrongly described")
+		}
+	}
+
+	// truncating up to the last event keeps it
+	if err := deleter.TruncateStream(ctx, aggregateID, aggregateType, last); err != nil {
+		return err
+	}
+
+	return es.SaveEvents(ctx, createEventsContinue(aggregateID))
+}
+
+// saveEventStreams describes the atomic save semantics:
+// either the events of every stream are saved or none of them are.
+func saveEventStreams(es hi.EventStore) error {
+	atomic, ok := es.(hi.AtomicEventStore)
+	if !ok {
+		return errNotSupported
+	}
+
+	ctx := context.Background()
+	first, second := idFunc(), idFunc()
+	if err := atomic.SaveEventStreams(ctx, [][]hi.Event{createEvents(first), createEvents(second)}); err != nil {
+		return err
+	}
+
+	for _, id := range []string{first, second} {
+		fetched, err := es.GetEvents(ctx, id, aggregateType, 0)
+		if err != nil {
+			return err
+		}
+		if len(fetched) != len(createEvents(id)) {
+			return errors.New("wrong number of events returned")
+		}
+	}
+
+	// the second stream conflicts, the first one must not be saved either
+	third := idFunc()
+	err := atomic.SaveEventStreams(ctx, [][]hi.Event{createEvents(third), createEvents(second)})
+	if !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency, got %v", err)
+	}
+
+	if _, err := es.GetEvents(ctx, third, aggregateType, 0); !errors.Is(err, hi.ErrNoEvents) {
+		return errors.New("events of a failed batch must not be saved")
+	}
+
+	err = atomic.SaveEventStreams(ctx, [][]hi.Event{createEventsContinue(first), createEventsContinue(first)})
+	if !errors.Is(err, ErrStreamRepeated) {
+		return fmt.Errorf("expected ErrStreamRepeated, got %v", err)
+	}
+
+	return nil
+}
+
 var idFunc = uuid.NewString
-var aggregateType = ""
+
+// concurrencyError describes the error returned when saving events not following the stream version
+func concurrencyError(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); err != nil {
+		return err
+	}
+
+	err := es.SaveEvents(ctx, createEvents(aggregateID))
+	if !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency, got %v", err)
+	}
+
+	var concurrency *hi.ConcurrencyError
+	if !errors.As(err, &concurrency) {
+		return fmt.Errorf("expected a *ConcurrencyError, got %T", err)
+	}
+
+	if concurrency.AggregateID != aggregateID {
+		return fmt.Errorf("wrong aggregate id %q", concurrency.AggregateID)
+	}
+
+	if concurrency.Expected != hi.ExpectNoStream || concurrency.Actual != 6 {
+		return fmt.Errorf("wrong versions, expected %s actual %d", concurrency.Expected, concurrency.Actual)
+	}
+
+	return nil
+}
+
+// idempotentAppend describes saving again events already stored with the same IDs and idempotency key at the same versions
+func idempotentAppend(es hi.EventStore) error {
+	ctx := context.Background()
+	aggregateID := idFunc()
+	events := createEvents(aggregateID)
+	for i := range events {
+		events[i].ID = idFunc()
+		events[i].Metadata = hi.EventMetadata{hi.MetadataIdempotencyKey: "command-1"}
+	}
+
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return err
+	}
+
+	// retrying the whole batch or part of it is a no-op
+	if err := es.SaveEvents(ctx, events); err != nil {
+		return fmt.Errorf("retrying an append should succeed, got %v", err)
+	}
+	if err := es.SaveEvents(ctx, events[2:4]); err != nil {
+		return fmt.Errorf("retrying part of an append should succeed, got %v", err)
+	}
+
+	fetched, err := es.GetEvents(ctx, aggregateID, aggregateType, 0)
+	if err != nil {
+		return err
+	}
+	if len(fetched) != len(events) {
+		return fmt.Errorf("expected %d events, got %d", len(events), len(fetched))
+	}
+
+	// other events at the same versions are still a conflict
+	conflicting := createEvents(aggregateID)
+	for i := range conflicting {
+		conflicting[i].ID = idFunc()
+	}
+	if err := es.SaveEvents(ctx, conflicting); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency, got %v", err)
+	}
+
+	// as well as the same events of another command, like concurrent writers using deterministic IDs
+	sameIDs := createEvents(aggregateID)
+	for i := range sameIDs {
+		sameIDs[i].ID = events[i].ID
+		sameIDs[i].Metadata = hi.EventMetadata{hi.MetadataIdempotencyKey: "command-2"}
+	}
+	if err := es.SaveEvents(ctx, sameIDs[5:]); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency for another idempotency key with the same id, got %v", err)
+	}
+
+	// or without idempotency key
+	for i := range sameIDs {
+		sameIDs[i].Metadata = nil
+	}
+	if err := es.SaveEvents(ctx, sameIDs[5:]); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency without idempotency key, got %v", err)
+	}
+
+	// as well as events without ID
+	if err := es.SaveEvents(ctx, createEvents(aggregateID)); !errors.Is(err, ErrConcurrency) {
+		return fmt.Errorf("expected ErrConcurrency, got %v", err)
+	}
+
+	return nil
+}
+
+// readAll describes reading the events of all the aggregates, in the order they were saved
+func readAll(es hi.EventStore) error {
+	reader, ok := es.(hi.EventLogReader)
+	if !ok {
+		return errNotSupported
+	}
+
+	ctx := context.Background()
+	first, second := idFunc(), idFunc()
+	for _, events := range [][]hi.Event{createEvents(first), createEvents(second), createEventsContinue(first)} {
+		if err := es.SaveEvents(ctx, events); err != nil {
+			return err
+		}
+	}
+
+	log, err := reader.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	var order []string
+	for i := range log {
+		if log[i].AggregateID == first || log[i].AggregateID == second {
+			order = append(order, fmt.Sprintf("%s@%d", log[i].AggregateID, log[i].Version))
+		}
+	}
+
+	var expected []string
+	for _, events := range [][]hi.Event{createEvents(first), createEvents(second), createEventsContinue(first)} {
+		for i := range events {
+			expected = append(expected, fmt.Sprintf("%s@%d", events[i].AggregateID, events[i].Version))
+		}
+	}
+
+	if !reflect.DeepEqual(order, expected) {
+		return fmt.Errorf("expected log %v, got %v", expected, order)
+	}
+	return nil
+}
+
+var aggregateType = hi.AggregateTypeOf(&acceptanceAggregate{})
 var timestamp = time.Now()
 
 type acceptanceAggregate struct {
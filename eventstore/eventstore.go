@@ -13,14 +13,19 @@ var (
 	// ErrEventMultipleAggregateTypes when events hold different aggregate types
 	ErrEventMultipleAggregateTypes = errors.New("events holds events for more than one aggregate type")
 
-	// ErrConcurrency when the currently saved version of the aggregate differs from the new ones
-	ErrConcurrency = errors.New("concurrency error")
+	// ErrConcurrency when the currently saved version of the aggregate differs from the new ones,
+	// it is the same error as historia.ErrConcurrency
+	ErrConcurrency = hi.ErrConcurrency
 
 	// ErrReasonMissing when the reason is not present in the events
 	ErrReasonMissing = errors.New("event holds no reason")
+
+	// ErrStreamRepeated when a batch of streams holds events for the same aggregate more than once
+	ErrStreamRepeated = errors.New("streams hold the same aggregate more than once")
 )
 
-// ValidateEvents make sure the incoming events are valid
+// ValidateEvents make sure the incoming events are valid,
+// events not following the current version result in a *historia.ConcurrencyError
 func ValidateEvents(aggregateID string, currentVersion hi.Version, events []hi.Event) error {
 	at := events[0].AggregateType
 
@@ -35,7 +40,16 @@ func ValidateEvents(aggregateID string, currentVersion hi.Version, events []hi.E
 		}
 
 		if currentVersion+1 != event.Version {
-			return ErrConcurrency
+			expected := hi.ExpectNoStream
+			if event.Version > 0 {
+				expected = hi.ExpectVersion(event.Version - 1)
+			}
+			return &hi.ConcurrencyError{
+				AggregateID:   aggregateID,
+				AggregateType: at,
+				Expected:      expected,
+				Actual:        currentVersion,
+			}
 		}
 
 		if event.Reason() == "" {
@@ -46,3 +60,35 @@ func ValidateEvents(aggregateID string, currentVersion hi.Version, events []hi.E
 	}
 	return nil
 }
+
+// AlreadySaved reports whether events are the retry of an append that succeeded:
+// each of them has an ID and an idempotency key, and an event with the same ID, idempotency key and reason
+// is stored at the same version. Stores treat saving such events as a successful no-op instead of a concurrency error.
+// Matching IDs alone aren't enough, deterministic IDs are shared by writers tracking the same change
+// while only retries of the same command share its idempotency key.
+func AlreadySaved(stored []hi.Event, events []hi.Event) bool {
+	if len(stored) == 0 || len(events) == 0 {
+		return false
+	}
+
+	// the stored events follow each other from the first one
+	first := stored[0].Version
+	for i := range events {
+		event := events[i]
+		if event.ID == "" || event.IdempotencyKey() == "" || event.Version < first {
+			return false
+		}
+
+		index := event.Version - first
+		if index >= hi.Version(len(stored)) {
+			return false
+		}
+
+		saved := stored[index]
+		if saved.Version != event.Version || saved.ID != event.ID ||
+			saved.IdempotencyKey() != event.IdempotencyKey() || saved.Reason() != event.Reason() {
+			return false
+		}
+	}
+	return true
+}
@@ -3,67 +3,191 @@ package memory
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/bansukai/historia"
 	"github.com/bansukai/historia/eventstore"
 )
 
+type Option func(m *Memory)
+
+// WithLogger sets the logger of the saved and rejected events
+func WithLogger(l historia.Logger) Option {
+	return func(m *Memory) {
+		m.logger = l
+	}
+}
+
+// WithNowFunc sets the clock stamping the tombstones of soft deleted streams, defaults to historia.Now
+func WithNowFunc(f func() time.Time) Option {
+	return func(m *Memory) {
+		m.nowFunc = f
+	}
+}
+
+// WithIDFunc sets the generator of the tombstone event IDs, defaults to historia.NewID
+func WithIDFunc(f func() string) Option {
+	return func(m *Memory) {
+		m.idFunc = f
+	}
+}
+
 // New in memory event store
-func New() *Memory {
-	return &Memory{
+func New(opts ...Option) *Memory {
+	m := &Memory{
 		aggregateEvents: make(map[string][]historia.Event),
 		allEvents:       make([]historia.Event, 0),
+		logger:          historia.NopLogger{},
+		nowFunc:         historia.Now,
+		idFunc:          historia.NewID,
+	}
+
+	for _, opt := range opts {
+		opt(m)
 	}
+
+	return m
 }
 
 // Memory is a handler for event streaming
 type Memory struct {
 	aggregateEvents map[string][]historia.Event
 	allEvents       []historia.Event
+	logger          historia.Logger
+	nowFunc         func() time.Time
+	idFunc          func() string
 	lock            sync.Mutex
 }
 
-// SaveEvents an aggregate (its events)
+// SaveEvents an aggregate (its events),
+// saving again events already stored with the same IDs at the same versions does nothing
 func (e *Memory) SaveEvents(ctx context.Context, events []historia.Event) error {
 	// Return if there is no events to save
 	if len(events) == 0 {
 		return nil
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// make sure its thread safe
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
+	if e.alreadySaved(events) {
+		e.log("events already saved", events)
+		return nil
+	}
+
+	if err := e.validate(events); err != nil {
+		e.log("events rejected", events, historia.LogKeyError, err)
+		return err
+	}
+
+	e.append(events)
+	e.log("events saved", events)
+	return nil
+}
+
+// SaveEventStreams saves the events of several aggregates, either all of them are saved or none
+func (e *Memory) SaveEventStreams(ctx context.Context, streams [][]historia.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	seen := make(map[string]struct{}, len(streams))
+	pending := make([][]historia.Event, 0, len(streams))
+	for _, events := range streams {
+		if len(events) == 0 {
+			continue
+		}
+
+		bucketName := aggregateKey(events[0].AggregateType, events[0].AggregateID)
+		if _, ok := seen[bucketName]; ok {
+			return eventstore.ErrStreamRepeated
+		}
+		seen[bucketName] = struct{}{}
+
+		if e.alreadySaved(events) {
+			continue
+		}
+
+		if err := e.validate(events); err != nil {
+			e.log("events rejected", events, historia.LogKeyError, err)
+			return err
+		}
+		pending = append(pending, events)
+	}
+
+	for _, events := range pending {
+		e.append(events)
+		e.log("events saved", events)
+	}
+	return nil
+}
+
+// log logs at debug level the message about the events, with extra fields
+func (e *Memory) log(msg string, events []historia.Event, fields ...interface{}) {
+	last := events[len(events)-1]
+	e.logger.Debug(msg, append([]interface{}{
+		historia.LogKeyAggregateType, last.AggregateType,
+		historia.LogKeyAggregateID, last.AggregateID,
+		historia.LogKeyVersion, last.Version,
+		historia.LogKeyEvents, len(events),
+	}, fields...)...)
+}
+
+// alreadySaved reports whether the events are a retry of a successful append
+func (e *Memory) alreadySaved(events []historia.Event) bool {
+	bucket := e.aggregateEvents[aggregateKey(events[0].AggregateType, events[0].AggregateID)]
+	return eventstore.AlreadySaved(bucket, events)
+}
+
+// validate makes sure the events can be appended to their aggregate bucket
+func (e *Memory) validate(events []historia.Event) error {
 	// get bucket name from first event
-	aggregateType := events[0].AggregateType
 	aggregateID := events[0].AggregateID
-	bucketName := aggregateKey(aggregateType, aggregateID)
-	bucket := e.aggregateEvents[bucketName]
+	bucket := e.aggregateEvents[aggregateKey(events[0].AggregateType, aggregateID)]
 	currentVersion := historia.Version(0)
 
 	if len(bucket) > 0 {
 		currentVersion = bucket[len(bucket)-1].Version
 	}
 
-	err := eventstore.ValidateEvents(aggregateID, currentVersion, events)
-	if err != nil {
-		return err
+	if isDeleted(bucket) {
+		return historia.ErrStreamDeleted
 	}
 
-	bucket = append(bucket, events...)
-	e.aggregateEvents[bucketName] = bucket
+	return eventstore.ValidateEvents(aggregateID, currentVersion, events)
+}
+
+// append adds validated events to their aggregate bucket
+func (e *Memory) append(events []historia.Event) {
+	bucketName := aggregateKey(events[0].AggregateType, events[0].AggregateID)
+	e.aggregateEvents[bucketName] = append(e.aggregateEvents[bucketName], events...)
 	e.allEvents = append(e.allEvents, events...)
-	return nil
 }
 
 // GetEvents aggregate events
 func (e *Memory) GetEvents(ctx context.Context, aggregateID string, aggregateType string, afterVersion historia.Version) ([]historia.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var events []historia.Event
 
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
 	aggEvents := e.aggregateEvents[aggregateKey(aggregateType, aggregateID)]
+	if isDeleted(aggEvents) {
+		return nil, historia.ErrStreamDeleted
+	}
+
 	for i := range aggEvents {
 		event := aggEvents[i]
 		if event.Version > afterVersion {
@@ -78,11 +202,162 @@ func (e *Memory) GetEvents(ctx context.Context, aggregateID string, aggregateTyp
 	return events, nil
 }
 
+// ReadAll returns the events of all the aggregates, in the order they were saved
+func (e *Memory) ReadAll(ctx context.Context) ([]historia.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	events := make([]historia.Event, len(e.allEvents))
+	copy(events, e.allEvents)
+	return events, nil
+}
+
+// StreamInfo describes the events stored for an aggregate
+func (e *Memory) StreamInfo(ctx context.Context, aggregateID string, aggregateType string) (*historia.StreamInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	bucket := e.aggregateEvents[aggregateKey(aggregateType, aggregateID)]
+	if len(bucket) == 0 {
+		return nil, historia.ErrNoEvents
+	}
+
+	first, last := bucket[0], bucket[len(bucket)-1]
+	return &historia.StreamInfo{
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		Version:       last.Version,
+		EventCount:    len(bucket),
+		FirstEventAt:  first.Timestamp,
+		LastEventAt:   last.Timestamp,
+		Deleted:       isDeleted(bucket),
+	}, nil
+}
+
+// SoftDeleteStream appends a tombstone event to the aggregate events
+func (e *Memory) SoftDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	bucketName := aggregateKey(aggregateType, aggregateID)
+	bucket := e.aggregateEvents[bucketName]
+	if len(bucket) == 0 {
+		return historia.ErrNoEvents
+	}
+
+	if isDeleted(bucket) {
+		return historia.ErrStreamDeleted
+	}
+
+	tombstone := historia.Event{
+		ID:            e.idFunc(),
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		Version:       bucket[len(bucket)-1].Version + 1,
+		Timestamp:     e.nowFunc(),
+		Data:          &historia.Tombstone{},
+	}
+
+	e.aggregateEvents[bucketName] = append(bucket, tombstone)
+	e.allEvents = append(e.allEvents, tombstone)
+	return nil
+}
+
+// HardDeleteStream removes all the aggregate events
+func (e *Memory) HardDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	bucketName := aggregateKey(aggregateType, aggregateID)
+	if len(e.aggregateEvents[bucketName]) == 0 {
+		return historia.ErrNoEvents
+	}
+
+	delete(e.aggregateEvents, bucketName)
+	e.removeFromLog(aggregateID, aggregateType, func(historia.Event) bool { return true })
+	return nil
+}
+
+// TruncateStream removes the aggregate events with a version lower than beforeVersion
+func (e *Memory) TruncateStream(ctx context.Context, aggregateID string, aggregateType string, beforeVersion historia.Version) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	bucketName := aggregateKey(aggregateType, aggregateID)
+	bucket := e.aggregateEvents[bucketName]
+	if len(bucket) == 0 {
+		return historia.ErrNoEvents
+	}
+
+	if isDeleted(bucket) {
+		return historia.ErrStreamDeleted
+	}
+
+	if beforeVersion > bucket[len(bucket)-1].Version {
+		return historia.ErrTruncateBeyondVersion
+	}
+
+	truncated := func(event historia.Event) bool { return event.Version < beforeVersion }
+	kept := make([]historia.Event, 0, len(bucket))
+	for i := range bucket {
+		if !truncated(bucket[i]) {
+			kept = append(kept, bucket[i])
+		}
+	}
+
+	e.aggregateEvents[bucketName] = kept
+	e.removeFromLog(aggregateID, aggregateType, truncated)
+	return nil
+}
+
 // Close does nothing
 func (e *Memory) Close() error {
 	return nil
 }
 
+// removeFromLog removes the events of the aggregate matching remove from the list of all events
+func (e *Memory) removeFromLog(aggregateID string, aggregateType string, remove func(event historia.Event) bool) {
+	kept := e.allEvents[:0]
+	for i := range e.allEvents {
+		event := e.allEvents[i]
+		if event.AggregateID == aggregateID && event.AggregateType == aggregateType && remove(event) {
+			continue
+		}
+		kept = append(kept, event)
+	}
+	e.allEvents = kept
+}
+
+// isDeleted reports whether the last event of the bucket is a tombstone
+func isDeleted(bucket []historia.Event) bool {
+	if len(bucket) == 0 {
+		return false
+	}
+
+	_, ok := bucket[len(bucket)-1].Data.(*historia.Tombstone)
+	return ok
+}
+
 // aggregateKey generate an aggregate key to store events against from aggregateType and aggregateID
 func aggregateKey(aggregateType, aggregateID string) string {
 	return aggregateType + "_" + aggregateID
@@ -1,11 +1,156 @@
 package memory
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/bansukai/historia"
 	"github.com/bansukai/historia/eventstore"
+	keys "github.com/bansukai/historia/keystore/memory"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMemoryStore(t *testing.T) {
 	eventstore.AcceptanceTest(t, New())
 }
+
+func Test_Memory_should_not_lose_concurrent_writes_with_deterministic_ids(t *testing.T) {
+	ctx := context.Background()
+	repo := historia.NewRepository(New(), nil, historia.WithRepositoryEventIDFunc(historia.DeterministicEventID))
+
+	acc := &account{}
+	assert.NoError(t, acc.TrackChange(acc, &deposited{Amount: 100}))
+	assert.NoError(t, repo.Save(ctx, acc))
+
+	// two callers making the same deposit get the same event IDs
+	w1, w2 := &account{}, &account{}
+	assert.NoError(t, repo.Get(ctx, acc.ID(), w1))
+	assert.NoError(t, repo.Get(ctx, acc.ID(), w2))
+
+	assert.NoError(t, w1.TrackChange(w1, &deposited{Amount: 100}))
+	assert.NoError(t, w2.TrackChange(w2, &deposited{Amount: 100}))
+	assert.NoError(t, repo.Save(historia.WithIdempotencyKey(ctx, "deposit-1"), w1))
+	assert.ErrorIs(t, repo.Save(historia.WithIdempotencyKey(ctx, "deposit-2"), w2), historia.ErrConcurrency)
+	assert.ErrorIs(t, repo.Save(ctx, w2), historia.ErrConcurrency, "without idempotency key")
+
+	balance := &account{}
+	assert.NoError(t, repo.Get(ctx, acc.ID(), balance))
+	assert.Equal(t, 200, balance.balance)
+}
+
+func Test_Memory_should_ignore_retried_commands(t *testing.T) {
+	ctx := historia.WithIdempotencyKey(context.Background(), "deposit-1")
+	repo := historia.NewRepository(New(), nil, historia.WithRepositoryEventIDFunc(historia.DeterministicEventID))
+
+	acc := &account{}
+	repo.Bind(acc)
+	assert.NoError(t, acc.SetID("acc"))
+	assert.NoError(t, acc.TrackChange(acc, &deposited{Amount: 100}))
+	events := acc.Events()
+	assert.NoError(t, repo.Save(ctx, acc))
+
+	// the same command tracked again after a lost acknowledgement
+	retried := &account{}
+	repo.Bind(retried)
+	assert.NoError(t, retried.SetID("acc"))
+	assert.NoError(t, retried.TrackChange(retried, &deposited{Amount: 100}))
+	assert.Equal(t, events[0].ID, retried.Events()[0].ID)
+	assert.NoError(t, repo.Save(ctx, retried))
+
+	balance := &account{}
+	assert.NoError(t, repo.Get(ctx, "acc", balance))
+	assert.Equal(t, 100, balance.balance)
+}
+
+func Test_Memory_should_keep_its_capabilities_behind_an_EncryptedEventStore(t *testing.T) {
+	ctx := historia.WithIdempotencyKey(context.Background(), "deposit-1")
+	registry := historia.NewEventRegistry()
+	assert.NoError(t, registry.Register(func() historia.EventData { return &deposited{} }))
+
+	m := New()
+	es := historia.NewEncryptedEventStore(m, keys.New(),
+		historia.WithEncryptionRegistry(registry), historia.WithPayloadEncryption(&deposited{}))
+	repo := historia.NewRepository(es, nil, historia.WithRepositoryEventIDFunc(historia.DeterministicEventID))
+
+	acc1, acc2 := &account{}, &account{}
+	assert.NoError(t, acc1.SetID("acc-1"))
+	assert.NoError(t, acc2.SetID("acc-2"))
+	uow := repo.NewUnitOfWork()
+	uow.Track(acc1, acc2)
+	assert.NoError(t, acc1.TrackChange(acc1, &deposited{Amount: 100}))
+	assert.NoError(t, acc2.TrackChange(acc2, &deposited{Amount: 50}))
+	assert.NoError(t, uow.Commit(ctx))
+
+	// retrying the command is a no-op even though the payload is encrypted with a new nonce
+	retried := &account{}
+	repo.Bind(retried)
+	assert.NoError(t, retried.SetID("acc-1"))
+	assert.NoError(t, retried.TrackChange(retried, &deposited{Amount: 100}))
+	assert.NoError(t, repo.Save(ctx, retried))
+
+	events, err := es.ReadAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, &deposited{Amount: 100}, events[0].Data)
+
+	stored, err := m.ReadAll(ctx)
+	assert.NoError(t, err)
+	assert.IsType(t, &historia.EncryptedPayload{}, stored[0].Data)
+
+	assert.NoError(t, repo.Delete(ctx, "acc-2", acc2))
+	exists, err := repo.Exists(ctx, "acc-2", &account{})
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func Test_Memory_should_stamp_tombstones_with_injected_clock_and_ids(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := New(WithNowFunc(func() time.Time { return now }), WithIDFunc(func() string { return "tombstone" }))
+	repo := historia.NewRepository(m, nil)
+
+	acc := &account{}
+	assert.NoError(t, acc.TrackChange(acc, &deposited{Amount: 10}))
+	assert.NoError(t, repo.Save(ctx, acc))
+	assert.NoError(t, repo.Delete(ctx, acc.ID(), acc))
+
+	events, err := m.ReadAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "tombstone", events[1].ID)
+	assert.Equal(t, now, events[1].Timestamp)
+}
+
+func Test_Memory_should_fail_loading_truncated_stream_without_snapshot(t *testing.T) {
+	ctx := context.Background()
+	repo := historia.NewRepository(New(), nil)
+
+	acc := &account{}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, acc.TrackChange(acc, &deposited{Amount: 10}))
+	}
+	assert.NoError(t, repo.Save(ctx, acc))
+	assert.NoError(t, repo.Truncate(ctx, acc.ID(), acc, 3))
+
+	assert.ErrorIs(t, repo.Get(ctx, acc.ID(), &account{}), historia.ErrHistoryGap)
+}
+
+// region mocks
+
+type account struct {
+	historia.AggregateBase
+	balance int
+}
+
+func (a *account) Transition(evt historia.Event) {
+	if e, ok := evt.Data.(*deposited); ok {
+		a.balance += e.Amount
+	}
+}
+
+type deposited struct {
+	Amount int
+}
+
+// endregion
@@ -7,14 +7,38 @@ import (
 	"sync"
 )
 
+type EventStreamOption func(e *EventStream)
+
+// WithEventStreamLogger sets the logger of subscriber failures
+func WithEventStreamLogger(l Logger) EventStreamOption {
+	return func(e *EventStream) {
+		e.logger = l
+	}
+}
+
+// WithEventStreamObserver sets the observer notified when subscribers handle events
+func WithEventStreamObserver(o Observer) EventStreamOption {
+	return func(e *EventStream) {
+		e.observer = o
+	}
+}
+
 // NewEventStream factory function
-func NewEventStream() *EventStream {
-	return &EventStream{
+func NewEventStream(opts ...EventStreamOption) *EventStream {
+	e := &EventStream{
 		aggregateTypes:     make(map[string][]*Subscription),
 		specificAggregates: make(map[string][]*Subscription),
 		specificEvents:     make(map[reflect.Type][]*Subscription),
 		allEvents:          []*Subscription{},
+		observer:           NopObserver{},
+		logger:             NopLogger{},
 	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
 }
 
 // Subscription holding the subscribe / unsubscribe / and func to be called when event matches the subscription
@@ -22,6 +46,9 @@ type Subscription struct {
 	f EventHandlerFunc
 	u func()
 	s func()
+
+	// kind names the subscriber function the subscription was made with, for logging
+	kind string
 }
 
 // Unsubscribe invokes the unsubscribe function
@@ -40,45 +67,67 @@ type EventStream struct {
 	specificAggregates map[string][]*Subscription
 	specificEvents     map[reflect.Type][]*Subscription
 	allEvents          []*Subscription
+	observer           Observer
+	logger             Logger
 
 	lock sync.Mutex
+
+	// publishLock serializes the publishes, subscribers are invoked one at a time
+	publishLock sync.Mutex
 }
 
 // Update invoke all event handling functions for subscriptions
 func (e *EventStream) Update(ctx context.Context, aggregate Aggregate, events []Event) error {
-	e.lock.Lock()
-	defer e.lock.Unlock()
-
-	for i := range events {
-		event := events[i]
-
-		// call all functions that has registered for all events
-		if err := updateAll(ctx, e, event); err != nil {
-			return err
-		}
+	return e.publish(ctx, formatAggregatePathType(aggregate), aggregate.Root().ID(), events)
+}
 
-		// call all functions that has registered for the specific event
-		if err := updateSpecificEvent(ctx, e, event); err != nil {
-			return err
-		}
+// publishingKey marks the contexts of the publishes of stream, holding its publish lock
+type publishingKey struct {
+	stream *EventStream
+}
 
-		// call all functions that has registered for the aggregate type events
-		if err := updateSpecificAggregateEvents(ctx, aggregate, e, event); err != nil {
-			return err
-		}
+// publish invokes the subscriptions of the events of the aggregate of type aggregateType and id aggregateID,
+// one publish at a time. Publishes made by subscribers with the context they are handed run within
+// the publish invoking them, so subscribers can save aggregates without deadlocking.
+// The subscriptions aren't locked while they are invoked, so subscribers can subscribe or unsubscribe.
+func (e *EventStream) publish(ctx context.Context, aggregateType, aggregateID string, events []Event) error {
+	key := publishingKey{stream: e}
+	if ctx.Value(key) == nil {
+		e.publishLock.Lock()
+		defer e.publishLock.Unlock()
+		ctx = context.WithValue(ctx, key, true)
+	}
 
-		// call all functions that has registered for the aggregate type and ID events
-		if err := updateSpecificAggregate(ctx, aggregate, e, event); err != nil {
-			return err
+	for i := range events {
+		event := events[i]
+		for _, s := range e.subscriptions(event, aggregateType, aggregateID) {
+			if err := e.handle(ctx, s, event); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// subscriptions returns the subscriptions matching the event, in the order they are invoked:
+// the subscriptions to all events, to the specific event, to the aggregate type and to the specific aggregate.
+func (e *EventStream) subscriptions(event Event, aggregateType, aggregateID string) []*Subscription {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var subs []*Subscription
+	subs = append(subs, e.allEvents...)
+	subs = append(subs, e.specificEvents[reflect.TypeOf(event.Data)]...)
+	subs = append(subs, e.aggregateTypes[aggregateType]...)
+	subs = append(subs, e.specificAggregates[aggregateType+"#"+aggregateID]...)
+	return subs
+}
+
 // SubscriberAll bind a function to be called on all events
 func (e *EventStream) SubscriberAll(f EventHandlerFunc) *Subscription {
 	s := Subscription{
-		f: f,
+		f:    f,
+		kind: "all",
 	}
 	s.u = func() {
 		e.lock.Lock()
@@ -103,7 +152,8 @@ func (e *EventStream) SubscriberAll(f EventHandlerFunc) *Subscription {
 // SubscriberSpecificAggregate bind a function to be called on events that happen on an aggregate based on type and ID
 func (e *EventStream) SubscriberSpecificAggregate(f EventHandlerFunc, aggregates ...Aggregate) *Subscription {
 	s := Subscription{
-		f: f,
+		f:    f,
+		kind: "specific_aggregate",
 	}
 	s.u = func() {
 		e.lock.Lock()
@@ -136,7 +186,8 @@ func (e *EventStream) SubscriberSpecificAggregate(f EventHandlerFunc, aggregates
 // SubscriberAggregateType bind a function to be called on events for an aggregate type
 func (e *EventStream) SubscriberAggregateType(f EventHandlerFunc, aggregates ...Aggregate) *Subscription {
 	s := Subscription{
-		f: f,
+		f:    f,
+		kind: "aggregate_type",
 	}
 	s.u = func() {
 		e.lock.Lock()
@@ -168,7 +219,8 @@ func (e *EventStream) SubscriberAggregateType(f EventHandlerFunc, aggregates ...
 // SubscriberSpecificEvent bind a function to be called on specific events
 func (e *EventStream) SubscriberSpecificEvent(f EventHandlerFunc, events ...EventData) *Subscription {
 	s := Subscription{
-		f: f,
+		f:    f,
+		kind: "specific_event",
 	}
 	s.u = func() {
 		e.lock.Lock()
@@ -197,49 +249,23 @@ func (e *EventStream) SubscriberSpecificEvent(f EventHandlerFunc, events ...Even
 	return &s
 }
 
-func updateAll(ctx context.Context, stream *EventStream, event Event) error {
-	for i := range stream.allEvents {
-		if err := stream.allEvents[i].f(ctx, event); err != nil {
-			return err
-		}
-	}
-	return nil
-}
+// handle calls the subscription function with the event, placed in its context
+func (e *EventStream) handle(ctx context.Context, s *Subscription, event Event) error {
+	ctx, end := e.observer.Publish(ContextWithEvent(ctx, event), event)
+	err := s.f(ctx, event)
+	end(err)
 
-func updateSpecificEvent(ctx context.Context, stream *EventStream, event Event) error {
-	t := reflect.TypeOf(event.Data)
-	if subs, ok := stream.specificEvents[t]; ok {
-		for i := range subs {
-			if err := subs[i].f(ctx, event); err != nil {
-				return err
-			}
-		}
+	if err != nil {
+		e.logger.Error("subscriber failed to handle event",
+			LogKeyAggregateType, event.AggregateType,
+			LogKeyAggregateID, event.AggregateID,
+			LogKeyVersion, event.Version,
+			LogKeyReason, event.Reason(),
+			LogKeySubscription, s.kind,
+			LogKeyError, err,
+		)
 	}
-	return nil
-}
-
-func updateSpecificAggregateEvents(ctx context.Context, aggregate Aggregate, stream *EventStream, event Event) error {
-	ref := formatAggregatePathType(aggregate)
-	if subs, ok := stream.aggregateTypes[ref]; ok {
-		for i := range subs {
-			if err := subs[i].f(ctx, event); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func updateSpecificAggregate(ctx context.Context, aggregate Aggregate, stream *EventStream, event Event) error {
-	ref := formatAggregatePathNameID(aggregate)
-	if subs, ok := stream.specificAggregates[ref]; ok {
-		for i := range subs {
-			if err := subs[i].f(ctx, event); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	return err
 }
 
 func formatAggregatePathType(aggregate Aggregate) string {
@@ -3,7 +3,10 @@ package historia
 import (
 	"context"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +50,46 @@ func Test_EventStream_SubscribeAll(t *testing.T) {
 	assert.Len(t, es.allEvents, 0)
 }
 
+func Test_EventStream_Update_should_invoke_subscribers_one_at_a_time(t *testing.T) {
+	var running, overlaps int32
+	es := NewEventStream()
+	es.SubscriberAll(func(context.Context, Event) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}).Subscribe()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, es.Update(context.Background(), &esAgg{}, []Event{{Version: 1, Data: &esEvent{}}}))
+		}()
+	}
+	wg.Wait()
+
+	assert.Zero(t, atomic.LoadInt32(&overlaps))
+}
+
+func Test_EventStream_Update_should_let_subscribers_publish(t *testing.T) {
+	var handled []Version
+	es := NewEventStream()
+	es.SubscriberAll(func(ctx context.Context, event Event) error {
+		handled = append(handled, event.Version)
+		if event.Version == 1 {
+			return es.Update(ctx, &esAgg{}, []Event{{Version: 2, Data: &esEvent{}}})
+		}
+		return nil
+	}).Subscribe()
+
+	assert.NoError(t, es.Update(context.Background(), &esAgg{}, []Event{{Version: 1, Data: &esEvent{}}}))
+	assert.Equal(t, []Version{1, 2}, handled)
+}
+
 func Test_EventStream_SubscribeSpecificEvent(t *testing.T) {
 	var streamEvent *Event
 	es := NewEventStream()
@@ -0,0 +1,235 @@
+package historiatest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	hi "github.com/bansukai/historia"
+	"github.com/stretchr/testify/assert"
+)
+
+// update rewrites golden files with the actual events instead of comparing them
+var update = flag.Bool("historia.update", false, "update the historia golden files")
+
+// goldenEvent is a line of a golden file
+type goldenEvent struct {
+	ID            string           `json:"id,omitempty"`
+	AggregateID   string           `json:"aggregate_id"`
+	AggregateType string           `json:"aggregate_type"`
+	Version       hi.Version       `json:"version"`
+	Timestamp     time.Time        `json:"timestamp"`
+	Type          string           `json:"type"`
+	Data          json.RawMessage  `json:"data"`
+	Metadata      hi.EventMetadata `json:"metadata,omitempty"`
+}
+
+// WriteGolden writes the events as JSON Lines, one event per line, naming event data with the registry
+func WriteGolden(w io.Writer, events []hi.Event, registry hi.EventRegistry) error {
+	enc := json.NewEncoder(w)
+	for i := range events {
+		event := events[i]
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("event %d: %w", i+1, err)
+		}
+
+		line := goldenEvent{
+			ID:            event.ID,
+			AggregateID:   event.AggregateID,
+			AggregateType: event.AggregateType,
+			Version:       event.Version,
+			Timestamp:     event.Timestamp,
+			Type:          registry.GetName(event.Data),
+			Data:          data,
+			Metadata:      event.Metadata,
+		}
+		if err := enc.Encode(line); err != nil {
+			return fmt.Errorf("event %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// ReadGolden reads events written by WriteGolden, creating their data with the registry.
+// Blank lines are ignored.
+func ReadGolden(r io.Reader, registry hi.EventRegistry) ([]hi.Event, error) {
+	var events []hi.Event
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var line goldenEvent
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		data, err := registry.Create(line.Type)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, line.Type, err)
+		}
+		if err := json.Unmarshal(line.Data, data); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		events = append(events, hi.Event{
+			ID:            line.ID,
+			AggregateID:   line.AggregateID,
+			AggregateType: line.AggregateType,
+			Version:       line.Version,
+			Timestamp:     line.Timestamp,
+			Data:          data,
+			Metadata:      line.Metadata,
+		})
+	}
+
+	return events, scanner.Err()
+}
+
+// LoadGolden reads the events of the golden file at path, failing the test when it can't
+func LoadGolden(t TestingT, path string, registry hi.EventRegistry) []hi.Event {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Errorf("opening golden file: %v", err)
+		t.FailNow()
+		return nil
+	}
+	defer f.Close()
+
+	events, err := ReadGolden(f, registry)
+	if err != nil {
+		t.Errorf("reading golden file %s: %v", path, err)
+		t.FailNow()
+	}
+	return events
+}
+
+// AssertGolden asserts the events are the ones of the golden file at path.
+// When the tests run with the -historia.update flag the golden file is written with the events instead.
+func AssertGolden(t TestingT, path string, events []hi.Event, registry hi.EventRegistry) {
+	t.Helper()
+
+	var actual bytes.Buffer
+	if err := WriteGolden(&actual, events, registry); err != nil {
+		t.Errorf("encoding events: %v", err)
+		return
+	}
+
+	if *update {
+		if err := writeFile(path, actual.Bytes()); err != nil {
+			t.Errorf("updating golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Errorf("golden file %s doesn't exist, run the tests with -historia.update to create it", path)
+		return
+	}
+	if err != nil {
+		t.Errorf("reading golden file: %v", err)
+		return
+	}
+
+	assert.Equal(t, string(expected), actual.String(), "events differ from golden file %s", path)
+}
+
+// Replay saves the events in the event store, in order, one batch for each run of events of the same aggregate
+func Replay(ctx context.Context, es hi.EventStore, events []hi.Event) error {
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && sameStream(events[start], events[end]) {
+			end++
+		}
+
+		if err := es.SaveEvents(ctx, events[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// ReplayAggregate builds the aggregate from the events of its stream,
+// skipping the events of other aggregates.
+func ReplayAggregate(aggregate hi.Aggregate, aggregateID string, events []hi.Event) error {
+	aggregateType := hi.AggregateTypeOf(aggregate)
+
+	var history []hi.Event
+	for i := range events {
+		if events[i].AggregateID == aggregateID && events[i].AggregateType == aggregateType {
+			history = append(history, events[i])
+		}
+	}
+
+	if len(history) == 0 {
+		return hi.ErrNoEvents
+	}
+	return aggregate.Root().BuildFromHistory(aggregate, history)
+}
+
+// Recorder captures the events published to its handler, to write them to golden files
+type Recorder struct {
+	events []hi.Event
+	lock   sync.Mutex
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Handle records the event, subscribe it to record the published events
+func (r *Recorder) Handle(_ context.Context, event hi.Event) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Events returns the recorded events
+func (r *Recorder) Events() []hi.Event {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	events := make([]hi.Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// WriteFile writes the recorded events to the golden file at path
+func (r *Recorder) WriteFile(path string, registry hi.EventRegistry) error {
+	var buf bytes.Buffer
+	if err := WriteGolden(&buf, r.Events(), registry); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+func sameStream(a, b hi.Event) bool {
+	return a.AggregateID == b.AggregateID && a.AggregateType == b.AggregateType
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
@@ -0,0 +1,104 @@
+package historiatest
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	hi "github.com/bansukai/historia"
+	"github.com/bansukai/historia/eventstore/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+const accountGolden = "testdata/account.jsonl"
+
+func Test_AssertGolden_should_match_recorded_events(t *testing.T) {
+	repo := hi.NewRepository(memory.New(), nil,
+		hi.WithRepositoryNowFunc(func() time.Time { return DefaultTime }),
+		hi.WithRepositoryIDFunc(NewSequentialIDFunc("id-")),
+	)
+
+	rec := NewRecorder()
+	repo.SubscriberAll(rec.Handle).Subscribe()
+
+	a := newAccount()
+	repo.Bind(a)
+	assert.NoError(t, a.Open("jane"))
+	assert.NoError(t, a.DepositWithNote(10, "salary"))
+	assert.NoError(t, a.Withdraw(4))
+	assert.NoError(t, repo.Save(context.Background(), a))
+
+	AssertGolden(t, accountGolden, rec.Events(), accountRegistry(t))
+}
+
+func Test_AssertGolden_should_report_differences(t *testing.T) {
+	rec := &recorder{}
+	events := LoadGolden(t, accountGolden, accountRegistry(t))
+	events[2].Data = &withdrawn{Amount: 5}
+
+	AssertGolden(rec, accountGolden, events, accountRegistry(t))
+	assert.Len(t, rec.errors, 1)
+
+	AssertGolden(rec, filepath.Join(t.TempDir(), "missing.jsonl"), events, accountRegistry(t))
+	assert.Contains(t, rec.errors[1], "-historia.update")
+}
+
+func Test_ReadGolden_should_round_trip_events(t *testing.T) {
+	events := []hi.Event{
+		{ID: "e1", AggregateID: "a1", AggregateType: "T", Version: 1, Timestamp: DefaultTime, Data: &opened{Owner: "jane"}},
+		{ID: "e2", AggregateID: "a1", AggregateType: "T", Version: 2, Timestamp: DefaultTime, Data: &deposited{Amount: 3}, Metadata: hi.EventMetadata{"note": "gift"}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGolden(&buf, events, accountRegistry(t)))
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+
+	read, err := ReadGolden(&buf, accountRegistry(t))
+	assert.NoError(t, err)
+	assert.Equal(t, events, read)
+}
+
+func Test_ReadGolden_should_return_error_for_unregistered_type(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGolden(&buf, []hi.Event{{Data: &closed{}}}, accountRegistry(t)))
+
+	_, err := ReadGolden(&buf, accountRegistry(t))
+	assert.ErrorIs(t, err, hi.ErrEventDataFactoryNotRegistered)
+}
+
+func Test_Replay_should_save_golden_events_in_store(t *testing.T) {
+	es := memory.New()
+	events := LoadGolden(t, accountGolden, accountRegistry(t))
+	assert.NoError(t, Replay(context.Background(), es, events))
+
+	a := newAccount()
+	assert.NoError(t, hi.NewRepository(es, nil).Get(context.Background(), "id-1", a))
+	assert.Equal(t, 6, a.balance)
+	assert.Equal(t, "jane", a.owner)
+}
+
+func Test_ReplayAggregate_should_build_aggregate_from_golden_events(t *testing.T) {
+	events := LoadGolden(t, accountGolden, accountRegistry(t))
+
+	a := newAccount()
+	assert.NoError(t, ReplayAggregate(a, "id-1", events))
+	assert.Equal(t, 6, a.balance)
+	assert.Equal(t, hi.Version(3), a.Version())
+
+	assert.ErrorIs(t, ReplayAggregate(newAccount(), "unknown", events), hi.ErrNoEvents)
+}
+
+func accountRegistry(t *testing.T) *hi.EventRegister {
+	r := hi.NewEventRegistry()
+	for _, f := range []func() hi.EventData{
+		func() hi.EventData { return &opened{} },
+		func() hi.EventData { return &deposited{} },
+		func() hi.EventData { return &withdrawn{} },
+	} {
+		assert.NoError(t, r.Register(f))
+	}
+	return r
+}
@@ -0,0 +1,243 @@
+// Package historiatest helps testing aggregates by describing their behaviours as scenarios:
+// given the events in the history of an aggregate, when a behaviour is invoked, then some events are tracked.
+package historiatest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	hi "github.com/bansukai/historia"
+	"github.com/stretchr/testify/assert"
+)
+
+// DefaultTime is the time of the clock of scenarios
+var DefaultTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// TestingT is the subset of testing.T used by scenarios
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	FailNow()
+}
+
+type ScenarioOption func(c *config)
+
+// WithNowFunc sets the clock of the aggregate, instead of always returning DefaultTime
+func WithNowFunc(f func() time.Time) ScenarioOption {
+	return func(c *config) {
+		c.nowFunc = f
+	}
+}
+
+// WithAggregateID sets the ID of the aggregate, instead of the first sequential ID
+func WithAggregateID(id string) ScenarioOption {
+	return func(c *config) {
+		c.aggregateID = id
+	}
+}
+
+// WithStrictEvents compares all the fields of the tracked events including their IDs and timestamps,
+// instead of only their data and metadata
+func WithStrictEvents() ScenarioOption {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+type config struct {
+	nowFunc     func() time.Time
+	aggregateID string
+	strict      bool
+}
+
+// NewScenario returns a scenario for the aggregate created by factory.
+// The aggregate clock always returns DefaultTime and IDs are generated in sequence, "id-1", "id-2" and so on,
+// so the tracked events are the same on every run.
+func NewScenario[A hi.Aggregate](t TestingT, factory func() A, opts ...ScenarioOption) *Scenario[A] {
+	c := config{
+		nowFunc: func() time.Time { return DefaultTime },
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return &Scenario[A]{
+		t:         t,
+		factory:   factory,
+		config:    c,
+		idFunc:    NewSequentialIDFunc("id-"),
+		aggregate: factory(),
+	}
+}
+
+// Scenario describes a behaviour of an aggregate
+type Scenario[A hi.Aggregate] struct {
+	t       TestingT
+	factory func() A
+	config  config
+	idFunc  func() string
+
+	history   []hi.Event
+	behaviour func(aggregate A) error
+
+	aggregate A
+	ran       bool
+	err       error
+}
+
+// Given adds events holding data to the history of the aggregate
+func (s *Scenario[A]) Given(data ...interface{}) *Scenario[A] {
+	for _, d := range data {
+		s.GivenEvents(hi.Event{Data: d})
+	}
+	return s
+}
+
+// GivenEvents adds events to the history of the aggregate.
+// Their aggregate, version, ID and timestamp are set by the scenario when empty.
+func (s *Scenario[A]) GivenEvents(events ...hi.Event) *Scenario[A] {
+	for i := range events {
+		event := events[i]
+		if event.AggregateID == "" {
+			event.AggregateID = s.aggregateID()
+		}
+		if event.AggregateType == "" {
+			event.AggregateType = hi.AggregateTypeOf(s.aggregate)
+		}
+		if event.Version == 0 {
+			event.Version = hi.Version(len(s.history) + 1)
+		}
+		if event.ID == "" {
+			event.ID = s.idFunc()
+		}
+		if event.Timestamp.IsZero() {
+			event.Timestamp = s.config.nowFunc()
+		}
+		s.history = append(s.history, event)
+	}
+	return s
+}
+
+// When sets the behaviour invoked on the aggregate built from the history
+func (s *Scenario[A]) When(behaviour func(aggregate A) error) *Scenario[A] {
+	s.behaviour = behaviour
+	return s
+}
+
+// Then asserts the behaviour succeeded and tracked events holding data, in order
+func (s *Scenario[A]) Then(data ...interface{}) {
+	s.t.Helper()
+
+	expected := make([]hi.Event, len(data))
+	for i := range data {
+		expected[i] = hi.Event{Data: data[i]}
+	}
+	s.then(expected, false)
+}
+
+// ThenEvents asserts the behaviour succeeded and tracked the events, in order.
+// Only their data and metadata are compared, unless the scenario uses WithStrictEvents.
+func (s *Scenario[A]) ThenEvents(events ...hi.Event) {
+	s.t.Helper()
+	s.then(events, true)
+}
+
+// ThenError asserts the behaviour failed with an error matching err according to errors.Is,
+// and that no events are left tracked on the aggregate.
+func (s *Scenario[A]) ThenError(err error) {
+	s.t.Helper()
+
+	s.run()
+	if !errors.Is(s.err, err) {
+		s.t.Errorf("expected error %q, got %v", err, s.err)
+		return
+	}
+	assert.Empty(s.t, s.aggregate.Root().Events(), "no events should be tracked on failure")
+}
+
+// Aggregate returns the aggregate after the behaviour was invoked,
+// to assert on its state
+func (s *Scenario[A]) Aggregate() A {
+	s.t.Helper()
+
+	s.run()
+	return s.aggregate
+}
+
+func (s *Scenario[A]) then(expected []hi.Event, withMetadata bool) {
+	s.t.Helper()
+
+	s.run()
+	if s.err != nil {
+		s.t.Errorf("unexpected error: %v", s.err)
+		return
+	}
+
+	actual := s.aggregate.Root().Events()
+	if !s.config.strict {
+		expected = project(expected, withMetadata)
+		actual = project(actual, withMetadata)
+	}
+	assert.Equal(s.t, expected, actual, "tracked events differ")
+}
+
+// run builds the aggregate from the history and invokes the behaviour, once
+func (s *Scenario[A]) run() {
+	s.t.Helper()
+
+	if s.ran {
+		return
+	}
+	s.ran = true
+
+	s.aggregate = s.factory()
+	root := s.aggregate.Root()
+	root.SetNowFunc(s.config.nowFunc)
+	root.SetIDFunc(s.idFunc)
+	if s.config.aggregateID != "" && len(s.history) == 0 {
+		if err := root.SetID(s.config.aggregateID); err != nil {
+			s.t.Errorf("setting the aggregate id: %v", err)
+			s.t.FailNow()
+		}
+	}
+
+	if err := root.BuildFromHistory(s.aggregate, s.history); err != nil {
+		s.t.Errorf("building the aggregate from the given events: %v", err)
+		s.t.FailNow()
+	}
+
+	if s.behaviour == nil {
+		return
+	}
+	s.err = s.behaviour(s.aggregate)
+}
+
+// aggregateID returns the ID of the aggregate the given events belong to
+func (s *Scenario[A]) aggregateID() string {
+	if s.config.aggregateID == "" {
+		s.config.aggregateID = s.idFunc()
+	}
+	return s.config.aggregateID
+}
+
+// project keeps the fields of the events compared by default
+func project(events []hi.Event, withMetadata bool) []hi.Event {
+	projected := make([]hi.Event, len(events))
+	for i := range events {
+		projected[i].Data = events[i].Data
+		if withMetadata {
+			projected[i].Metadata = events[i].Metadata
+		}
+	}
+	return projected
+}
+
+// NewSequentialIDFunc returns a generator of the IDs prefix1, prefix2 and so on
+func NewSequentialIDFunc(prefix string) func() string {
+	n := 0
+	return func() string {
+		n++
+		return fmt.Sprintf("%s%d", prefix, n)
+	}
+}
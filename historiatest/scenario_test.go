@@ -0,0 +1,185 @@
+package historiatest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	hi "github.com/bansukai/historia"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Scenario_Then_should_pass_when_events_match(t *testing.T) {
+	NewScenario(t, newAccount).
+		Given(&opened{Owner: "jane"}, &deposited{Amount: 10}).
+		When(func(a *account) error { return a.Withdraw(4) }).
+		Then(&withdrawn{Amount: 4})
+}
+
+func Test_Scenario_Then_should_report_mismatch(t *testing.T) {
+	rec := &recorder{}
+	NewScenario(rec, newAccount).
+		Given(&opened{}, &deposited{Amount: 10}).
+		When(func(a *account) error { return a.Withdraw(4) }).
+		Then(&withdrawn{Amount: 5})
+
+	assert.Len(t, rec.errors, 1)
+	assert.Contains(t, rec.errors[0], "tracked events differ")
+	assert.Contains(t, rec.errors[0], "Diff:")
+}
+
+func Test_Scenario_Then_should_report_unexpected_error(t *testing.T) {
+	rec := &recorder{}
+	NewScenario(rec, newAccount).
+		Given(&opened{}).
+		When(func(a *account) error { return a.Withdraw(4) }).
+		Then(&withdrawn{Amount: 4})
+
+	assert.Equal(t, []string{"unexpected error: insufficient funds"}, rec.errors)
+}
+
+func Test_Scenario_ThenError(t *testing.T) {
+	NewScenario(t, newAccount).
+		Given(&opened{}).
+		When(func(a *account) error { return a.Withdraw(4) }).
+		ThenError(errInsufficientFunds)
+
+	rec := &recorder{}
+	NewScenario(rec, newAccount).
+		Given(&opened{}, &deposited{Amount: 10}).
+		When(func(a *account) error { return a.Withdraw(4) }).
+		ThenError(errInsufficientFunds)
+	assert.Len(t, rec.errors, 1)
+}
+
+func Test_Scenario_ThenEvents_should_compare_metadata(t *testing.T) {
+	NewScenario(t, newAccount).
+		Given(&opened{}).
+		When(func(a *account) error { return a.DepositWithNote(3, "gift") }).
+		ThenEvents(hi.Event{Data: &deposited{Amount: 3}, Metadata: hi.EventMetadata{"note": "gift"}})
+
+	rec := &recorder{}
+	NewScenario(rec, newAccount).
+		Given(&opened{}).
+		When(func(a *account) error { return a.DepositWithNote(3, "gift") }).
+		ThenEvents(hi.Event{Data: &deposited{Amount: 3}})
+	assert.Len(t, rec.errors, 1)
+}
+
+func Test_Scenario_should_be_deterministic(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	NewScenario(t, newAccount, WithStrictEvents(), WithNowFunc(func() time.Time { return now })).
+		When(func(a *account) error { return a.Open("jane") }).
+		ThenEvents(hi.Event{
+			ID:            "id-2",
+			AggregateID:   "id-1",
+			AggregateType: hi.AggregateTypeOf(&account{}),
+			Version:       1,
+			Timestamp:     now,
+			Data:          &opened{Owner: "jane"},
+		})
+}
+
+func Test_Scenario_Aggregate_should_return_state_after_behaviour(t *testing.T) {
+	s := NewScenario(t, newAccount, WithAggregateID("acc-1")).
+		Given(&opened{}, &deposited{Amount: 10}).
+		When(func(a *account) error { return a.Withdraw(4) })
+
+	a := s.Aggregate()
+	assert.Equal(t, 6, a.balance)
+	assert.Equal(t, "acc-1", a.ID())
+	assert.Equal(t, hi.Version(3), a.Version())
+}
+
+func Test_Scenario_should_fail_when_history_cant_be_applied(t *testing.T) {
+	rec := &recorder{}
+	NewScenario(rec, newAccount).
+		Given(&opened{}, &closed{}).
+		Then()
+
+	assert.True(t, rec.failed)
+	assert.Contains(t, rec.errors[0], "building the aggregate from the given events")
+}
+
+func Test_NewSequentialIDFunc(t *testing.T) {
+	next := NewSequentialIDFunc("evt-")
+	assert.Equal(t, "evt-1", next())
+	assert.Equal(t, "evt-2", next())
+}
+
+// region mocks
+
+var errInsufficientFunds = errors.New("insufficient funds")
+
+type account struct {
+	hi.AggregateBase
+	owner   string
+	balance int
+}
+
+func newAccount() *account {
+	return &account{}
+}
+
+func (a *account) TransitionE(evt hi.Event) error {
+	switch e := evt.Data.(type) {
+	case *opened:
+		a.owner = e.Owner
+	case *deposited:
+		a.balance += e.Amount
+	case *withdrawn:
+		a.balance -= e.Amount
+	default:
+		return fmt.Errorf("unknown event %T", e)
+	}
+	return nil
+}
+
+func (a *account) Transition(hi.Event) {}
+
+func (a *account) Open(owner string) error {
+	return a.TrackChange(a, &opened{Owner: owner})
+}
+
+func (a *account) DepositWithNote(amount int, note string) error {
+	return a.TrackChangeWithMetadata(a, &deposited{Amount: amount}, map[string]interface{}{"note": note})
+}
+
+func (a *account) Withdraw(amount int) error {
+	if a.balance < amount {
+		return errInsufficientFunds
+	}
+	return a.TrackChange(a, &withdrawn{Amount: amount})
+}
+
+type opened struct {
+	Owner string
+}
+
+type deposited struct {
+	Amount int
+}
+
+type withdrawn struct {
+	Amount int
+}
+
+type closed struct{}
+
+type recorder struct {
+	errors []string
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) FailNow() {
+	r.failed = true
+}
+
+// endregion
@@ -1,23 +1,68 @@
 package historia
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
 	"github.com/google/uuid"
 )
 
+// EventIDNamespace is the UUID namespace of the ids generated by DeterministicEventID
+var EventIDNamespace = uuid.MustParse("5b1f3f5e-8c4a-4c2e-9d0e-6f1b7a2c3d4e")
+
+// EventIDFunc generates the ID of the event holding data stored at version in an aggregate stream
+type EventIDFunc func(aggregateType, aggregateID string, version Version, data EventData) string
+
 // idFunc is a global function that generates aggregate id's.
-// It can be changed from the outside via the SetIDFunc function.
+// It can be changed from the outside via the SetIDFunc function,
+// and is used by aggregates and repositories that don't have their own generator.
 var idFunc = defaultIDGenerator
 
+// eventIDFunc is a global function that generates event id's, when nil the idFunc is used.
+// It can be changed from the outside via the SetEventIDFunc function.
+var eventIDFunc EventIDFunc
+
 // NewID generates a unique id using the configured generator.
 func NewID() string {
 	return idFunc()
 }
 
-// SetIDFunc is used to change how aggregate IDs are generated.
+// SetIDFunc is used to change how aggregate IDs are generated by default.
 func SetIDFunc(f func() string) {
 	idFunc = f
 }
 
+// SetEventIDFunc is used to change how event IDs are generated by default,
+// independently of the aggregate IDs. Passing nil generates event IDs like aggregate IDs again.
+func SetEventIDFunc(f EventIDFunc) {
+	eventIDFunc = f
+}
+
+// DeterministicEventID is an EventIDFunc returning a name based (version 5) UUID of the stream, version and data.
+// Tracking the same change again yields the same ID, whoever tracks it, so the ID alone can't tell a retry
+// from another writer: stores only treat saving events again as a retry when they carry the same
+// idempotency key, see WithIdempotencyKey.
+func DeterministicEventID(aggregateType, aggregateID string, version Version, data EventData) string {
+	name := fmt.Sprintf("%s#%s@%d:%s", aggregateType, aggregateID, version, payloadDigest(data))
+	return uuid.NewSHA1(EventIDNamespace, []byte(name)).String()
+}
+
+// payloadDigest returns a hash of the type and content of the event data
+func payloadDigest(data EventData) string {
+	content, err := json.Marshal(data)
+	if err != nil {
+		// data that can't be marshalled is digested from its printed value
+		content = []byte(fmt.Sprintf("%#v", data))
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%T:", data)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func defaultIDGenerator() string {
 	return uuid.NewString()
 }
@@ -0,0 +1,42 @@
+package historia
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DeterministicEventID_should_be_stable_per_stream_and_version(t *testing.T) {
+	id := DeterministicEventID("orders.Order", "123", 1, &born{Name: "a"})
+	assert.Equal(t, id, DeterministicEventID("orders.Order", "123", 1, &born{Name: "a"}))
+	assert.NotEqual(t, id, DeterministicEventID("orders.Order", "123", 2, &born{Name: "a"}))
+	assert.NotEqual(t, id, DeterministicEventID("orders.Order", "456", 1, &born{Name: "a"}))
+	assert.NotEqual(t, id, DeterministicEventID("orders.Invoice", "123", 1, &born{Name: "a"}))
+
+	u, err := uuid.Parse(id)
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(5), u.Version())
+}
+
+func Test_DeterministicEventID_should_differ_for_different_events_at_the_same_version(t *testing.T) {
+	id := DeterministicEventID("orders.Order", "123", 1, &born{Name: "a"})
+	assert.NotEqual(t, id, DeterministicEventID("orders.Order", "123", 1, &born{Name: "b"}))
+	assert.NotEqual(t, id, DeterministicEventID("orders.Order", "123", 1, &agedOneYear{}))
+	assert.NotEqual(t, DeterministicEventID("orders.Order", "123", 1, &born{}), DeterministicEventID("orders.Order", "123", 1, &agedOneYear{}))
+}
+
+func Test_SetEventIDFunc_should_generate_event_ids_independently(t *testing.T) {
+	SetIDFunc(func() string { return "aggregate" })
+	SetEventIDFunc(DeterministicEventID)
+	defer func() {
+		SetIDFunc(defaultIDGenerator)
+		SetEventIDFunc(nil)
+	}()
+
+	p := aggAgg{}
+	p.TrackChange(&p, &born{})
+
+	assert.Equal(t, "aggregate", p.ID())
+	assert.Equal(t, DeterministicEventID(formatAggregatePathType(&p), "aggregate", 1, &born{}), p.events[0].ID)
+}
@@ -0,0 +1,44 @@
+package keystore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bansukai/historia"
+	"github.com/stretchr/testify/assert"
+)
+
+func AcceptanceTestKeyStore(t *testing.T, keys historia.KeyStore) {
+	ctx := context.Background()
+
+	_, err := keys.Get(ctx, "bogus")
+	assert.ErrorIs(t, err, historia.ErrKeyNotFound)
+
+	created, err := keys.Create(ctx, "123")
+	assert.NoError(t, err)
+	assert.Len(t, created, 32)
+
+	again, err := keys.Create(ctx, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, created, again)
+
+	actual, err := keys.Get(ctx, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, created, actual)
+
+	other, err := keys.Create(ctx, "456")
+	assert.NoError(t, err)
+	assert.NotEqual(t, created, other)
+
+	assert.NoError(t, keys.Delete(ctx, "123"))
+	_, err = keys.Get(ctx, "123")
+	assert.ErrorIs(t, err, historia.ErrKeyNotFound)
+
+	// shredding is permanent
+	_, err = keys.Create(ctx, "123")
+	assert.ErrorIs(t, err, historia.ErrKeyShredded)
+	_, err = keys.Get(ctx, "123")
+	assert.ErrorIs(t, err, historia.ErrKeyNotFound)
+
+	assert.NoError(t, keys.Delete(ctx, "123"))
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"sync"
+
+	"github.com/bansukai/historia"
+)
+
+const keySize = 32
+
+// New in memory key store
+func New() *Memory {
+	return &Memory{
+		keys:     make(map[string][]byte),
+		shredded: make(map[string]struct{}),
+	}
+}
+
+// Memory of key store
+type Memory struct {
+	keys     map[string][]byte
+	shredded map[string]struct{}
+	lock     sync.RWMutex
+}
+
+func (m *Memory) Get(_ context.Context, subjectID string) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	key, ok := m.keys[subjectID]
+	if !ok {
+		return nil, historia.ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (m *Memory) Create(_ context.Context, subjectID string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if key, ok := m.keys[subjectID]; ok {
+		return key, nil
+	}
+	if _, ok := m.shredded[subjectID]; ok {
+		return nil, historia.ErrKeyShredded
+	}
+
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	m.keys[subjectID] = key
+	return key, nil
+}
+
+func (m *Memory) Delete(_ context.Context, subjectID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.keys, subjectID)
+	m.shredded[subjectID] = struct{}{}
+	return nil
+}
@@ -0,0 +1,11 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/bansukai/historia/keystore"
+)
+
+func TestStore(t *testing.T) {
+	keystore.AcceptanceTestKeyStore(t, New())
+}
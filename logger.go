@@ -0,0 +1,29 @@
+package historia
+
+// Keys of the structured fields logged by historia
+const (
+	LogKeyAggregateType = "aggregate_type"
+	LogKeyAggregateID   = "aggregate_id"
+	LogKeyVersion       = "version"
+	LogKeyReason        = "reason"
+	LogKeyEvents        = "events"
+	LogKeySubscription  = "subscription"
+	LogKeyError         = "error"
+)
+
+// Logger logs messages with structured fields given as alternating keys and values,
+// *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// NopLogger is a Logger discarding everything, the default one
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...interface{}) {}
+func (NopLogger) Info(string, ...interface{})  {}
+func (NopLogger) Warn(string, ...interface{})  {}
+func (NopLogger) Error(string, ...interface{}) {}
@@ -0,0 +1,27 @@
+//go:build go1.21
+
+package historia
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Logger_should_be_implemented_by_slog(t *testing.T) {
+	var buf bytes.Buffer
+	var logger Logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	stream := NewEventStream(WithEventStreamLogger(logger))
+	stream.SubscriberAll(func(context.Context, Event) error { return assert.AnError }).Subscribe()
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	assert.Error(t, stream.Update(context.Background(), agg, agg.Events()))
+	assert.Contains(t, buf.String(), "level=ERROR")
+	assert.Contains(t, buf.String(), "subscription=all")
+	assert.Contains(t, buf.String(), "reason=repoEvent1")
+}
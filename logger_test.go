@@ -0,0 +1,130 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EventStream_should_log_subscriber_failures(t *testing.T) {
+	logger := &loggerMocker{}
+	stream := NewEventStream(WithEventStreamLogger(logger))
+
+	errHandler := errors.New("handler failed")
+	stream.SubscriberSpecificEvent(func(context.Context, Event) error { return errHandler }, &repoEvent1{}).Subscribe()
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	assert.ErrorIs(t, stream.Update(context.Background(), agg, agg.Events()), errHandler)
+
+	assert.Len(t, logger.entries, 1)
+	entry := logger.entries[0]
+	assert.Equal(t, "error", entry.level)
+	assert.Equal(t, "specific_event", entry.field(LogKeySubscription))
+	assert.Equal(t, "repoEvent1", entry.field(LogKeyReason))
+	assert.Equal(t, agg.ID(), entry.field(LogKeyAggregateID))
+	assert.Equal(t, Version(1), entry.field(LogKeyVersion))
+	assert.Equal(t, errHandler, entry.field(LogKeyError))
+}
+
+func Test_Repo_should_log_loads_and_saves(t *testing.T) {
+	logger := &loggerMocker{}
+	var stored []Event
+	es := &eventStoreMocker{
+		save: func(_ context.Context, events []Event) error {
+			stored = append(stored, events...)
+			return nil
+		},
+		get: func(context.Context, string, string, Version) ([]Event, error) {
+			return stored, nil
+		},
+	}
+	repo := NewRepository(es, nil, WithRepositoryLogger(logger))
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	assert.NoError(t, repo.Save(context.Background(), agg))
+	assert.NoError(t, repo.Get(context.Background(), agg.ID(), &repoAggregate{}))
+
+	assert.Equal(t, []string{"aggregate saved", "aggregate loaded"}, logger.messages())
+	assert.Equal(t, 1, logger.entries[0].field(LogKeyEvents))
+	assert.Equal(t, Version(1), logger.entries[1].field(LogKeyVersion))
+}
+
+func Test_Repo_Save_should_log_invariant_violations(t *testing.T) {
+	logger := &loggerMocker{}
+	repo := NewRepository(&eventStoreMocker{}, nil, WithRepositoryLogger(logger))
+
+	agg := &invAgg{maxAge: 5}
+	_ = agg.TrackChange(agg, &born{Name: "Someone"})
+	agg.maxAge = -1
+
+	assert.ErrorIs(t, repo.Save(context.Background(), agg), ErrInvariantViolation)
+	assert.Len(t, logger.entries, 1)
+	assert.Equal(t, "warn", logger.entries[0].level)
+}
+
+func Test_Snapper_should_log_snapshot_failures(t *testing.T) {
+	logger := &loggerMocker{}
+	errUnmarshal := errors.New("corrupted")
+	ss := &snapStoreMocker{
+		get: func(context.Context, string, string) (*Snapshot, error) {
+			return &Snapshot{ID: "1", Type: "T", Version: 3}, nil
+		},
+	}
+	m := NewMarshal(nil, func([]byte, interface{}) error { return errUnmarshal })
+	snapper := NewSnapper(ss, m, WithSnapperLogger(logger))
+
+	agg := &ssAggWithSnapshot{}
+	assert.ErrorIs(t, snapper.ApplySnapshot(context.Background(), "1", agg), errUnmarshal)
+	assert.Equal(t, []string{"unmarshalling snapshot failed"}, logger.messages())
+	assert.Equal(t, Version(3), logger.entries[0].field(LogKeyVersion))
+}
+
+func Test_NopLogger_should_be_the_default(t *testing.T) {
+	assert.Equal(t, NopLogger{}, NewRepository(nil, nil).logger)
+	assert.Equal(t, NopLogger{}, NewSnapper(nil, nil).logger)
+	assert.Equal(t, NopLogger{}, NewEventStream().logger)
+}
+
+// region mocks
+
+type logEntry struct {
+	level string
+	msg   string
+	args  []interface{}
+}
+
+func (e logEntry) field(key string) interface{} {
+	for i := 0; i+1 < len(e.args); i += 2 {
+		if e.args[i] == key {
+			return e.args[i+1]
+		}
+	}
+	return nil
+}
+
+type loggerMocker struct {
+	entries []logEntry
+}
+
+func (l *loggerMocker) Debug(msg string, args ...interface{}) { l.log("debug", msg, args) }
+func (l *loggerMocker) Info(msg string, args ...interface{})  { l.log("info", msg, args) }
+func (l *loggerMocker) Warn(msg string, args ...interface{})  { l.log("warn", msg, args) }
+func (l *loggerMocker) Error(msg string, args ...interface{}) { l.log("error", msg, args) }
+
+func (l *loggerMocker) log(level, msg string, args []interface{}) {
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, args: args})
+}
+
+func (l *loggerMocker) messages() []string {
+	msgs := make([]string, len(l.entries))
+	for i := range l.entries {
+		msgs[i] = l.entries[i].msg
+	}
+	return msgs
+}
+
+// endregion
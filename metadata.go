@@ -0,0 +1,120 @@
+package historia
+
+import (
+	"context"
+)
+
+// Keys of the standard event metadata
+const (
+	MetadataCorrelationID = "correlation_id"
+	MetadataCausationID   = "causation_id"
+	MetadataUserID        = "user_id"
+	MetadataRequestID     = "request_id"
+
+	// MetadataIdempotencyKey identifies the command that produced the events, saving events again
+	// with the same key and IDs at the same versions is a successful no-op instead of a conflict
+	MetadataIdempotencyKey = "idempotency_key"
+)
+
+// MetadataEnricher returns metadata to add to the events saved with ctx
+type MetadataEnricher func(ctx context.Context) map[string]interface{}
+
+type metadataKey string
+
+// WithCorrelationID returns a context whose saved events have the correlation id
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, metadataKey(MetadataCorrelationID), id)
+}
+
+// WithCausationID returns a context whose saved events have the causation id
+func WithCausationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, metadataKey(MetadataCausationID), id)
+}
+
+// WithUserID returns a context whose saved events have the user id
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, metadataKey(MetadataUserID), id)
+}
+
+// WithRequestID returns a context whose saved events have the request id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, metadataKey(MetadataRequestID), id)
+}
+
+// WithIdempotencyKey returns a context whose saved events have the idempotency key.
+// The key identifies the command, retries of it must use the same key and other commands another one.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, metadataKey(MetadataIdempotencyKey), key)
+}
+
+// MetadataFromContext returns the value of the standard metadata key placed in ctx, empty if there is none
+func MetadataFromContext(ctx context.Context, key string) string {
+	id, _ := ctx.Value(metadataKey(key)).(string)
+	return id
+}
+
+// ContextMetadata is the default MetadataEnricher, returning the standard metadata placed in ctx
+// with WithCorrelationID, WithCausationID, WithUserID, WithRequestID and WithIdempotencyKey.
+// When ctx holds the event being handled by a subscriber, the saved events are caused by it
+// and share its correlation, whatever the causation and correlation IDs placed in ctx.
+func ContextMetadata(ctx context.Context) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for _, key := range []string{MetadataCorrelationID, MetadataCausationID, MetadataUserID, MetadataRequestID, MetadataIdempotencyKey} {
+		if v := MetadataFromContext(ctx, key); v != "" {
+			metadata[key] = v
+		}
+	}
+
+	for k, v := range causationMetadata(ctx) {
+		metadata[k] = v
+	}
+	return metadata
+}
+
+// CorrelationID returns the correlation id metadata of the event
+func (e Event) CorrelationID() string {
+	return e.metadataString(MetadataCorrelationID)
+}
+
+// CausationID returns the causation id metadata of the event
+func (e Event) CausationID() string {
+	return e.metadataString(MetadataCausationID)
+}
+
+// UserID returns the user id metadata of the event
+func (e Event) UserID() string {
+	return e.metadataString(MetadataUserID)
+}
+
+// RequestID returns the request id metadata of the event
+func (e Event) RequestID() string {
+	return e.metadataString(MetadataRequestID)
+}
+
+// IdempotencyKey returns the idempotency key metadata of the event
+func (e Event) IdempotencyKey() string {
+	return e.metadataString(MetadataIdempotencyKey)
+}
+
+func (e Event) metadataString(key string) string {
+	v, _ := e.Metadata[key].(string)
+	return v
+}
+
+// enrich merges the metadata of the enrichers into the events, keeping the values the events already hold
+func enrich(ctx context.Context, enrichers []MetadataEnricher, events []Event) {
+	extra := make(map[string]interface{})
+	for _, enricher := range enrichers {
+		for k, v := range enricher(ctx) {
+			extra[k] = v
+		}
+	}
+
+	if len(extra) == 0 {
+		return
+	}
+
+	for i := range events {
+		events[i].Metadata = mergeMetadata(events[i].Metadata, extra)
+	}
+}
@@ -0,0 +1,112 @@
+package historia
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ContextMetadata_should_return_standard_metadata(t *testing.T) {
+	ctx := WithCorrelationID(context.Background(), "corr")
+	ctx = WithCausationID(ctx, "cause")
+	ctx = WithUserID(ctx, "jane")
+	ctx = WithRequestID(ctx, "req")
+	ctx = WithIdempotencyKey(ctx, "cmd")
+
+	assert.Equal(t, map[string]interface{}{
+		MetadataCorrelationID:  "corr",
+		MetadataCausationID:    "cause",
+		MetadataUserID:         "jane",
+		MetadataRequestID:      "req",
+		MetadataIdempotencyKey: "cmd",
+	}, ContextMetadata(ctx))
+	assert.Empty(t, ContextMetadata(context.Background()))
+}
+
+func Test_Event_metadata_accessors(t *testing.T) {
+	e := Event{Metadata: EventMetadata{
+		MetadataCorrelationID:  "corr",
+		MetadataCausationID:    "cause",
+		MetadataUserID:         "jane",
+		MetadataRequestID:      42,
+		MetadataIdempotencyKey: "cmd",
+	}}
+
+	assert.Equal(t, "corr", e.CorrelationID())
+	assert.Equal(t, "cause", e.CausationID())
+	assert.Equal(t, "jane", e.UserID())
+	assert.Equal(t, "", e.RequestID(), "only strings are returned")
+	assert.Equal(t, "cmd", e.IdempotencyKey())
+	assert.Equal(t, "", Event{}.CorrelationID())
+}
+
+func Test_Repo_Save_should_enrich_metadata_from_context(t *testing.T) {
+	var saved, published []Event
+	es := &eventStoreMocker{
+		save: func(_ context.Context, events []Event) error {
+			saved = events
+			return nil
+		},
+	}
+	repo := NewRepository(es, nil)
+	repo.SubscriberAll(func(_ context.Context, e Event) error {
+		published = append(published, e)
+		return nil
+	}).Subscribe()
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChangeWithMetadata(agg, &repoEvent1{}, map[string]interface{}{MetadataUserID: "explicit"})
+	_ = agg.TrackChange(agg, &repoEvent1{})
+
+	ctx := WithUserID(WithCorrelationID(context.Background(), "corr"), "jane")
+	assert.NoError(t, repo.Save(ctx, agg))
+
+	assert.Equal(t, EventMetadata{MetadataCorrelationID: "corr", MetadataUserID: "explicit"}, saved[0].Metadata)
+	assert.Equal(t, EventMetadata{MetadataCorrelationID: "corr", MetadataUserID: "jane"}, saved[1].Metadata)
+	assert.Equal(t, saved, published)
+}
+
+func Test_Repo_should_use_configured_enrichers(t *testing.T) {
+	var saved []Event
+	es := &eventStoreMocker{
+		save: func(_ context.Context, events []Event) error {
+			saved = events
+			return nil
+		},
+	}
+
+	tenant := func(context.Context) map[string]interface{} {
+		return map[string]interface{}{"tenant": "acme", MetadataUserID: "system"}
+	}
+	repo := NewRepository(es, nil, WithRepositoryEnrichers(ContextMetadata, tenant))
+
+	ctx := WithUserID(WithRequestID(context.Background(), "req"), "jane")
+	assert.NoError(t, repo.AppendToStream(ctx, "T", "1", ExpectNoStream, []Event{{Data: &repoEvent1{}}}))
+	assert.Equal(t, EventMetadata{"tenant": "acme", MetadataUserID: "system", MetadataRequestID: "req"}, saved[0].Metadata)
+
+	repo = NewRepository(es, nil, WithRepositoryEnrichers())
+	assert.NoError(t, repo.AppendToStream(ctx, "T", "1", ExpectNoStream, []Event{{Data: &repoEvent1{}}}))
+	assert.Nil(t, saved[0].Metadata)
+}
+
+func Test_UnitOfWork_Commit_should_enrich_metadata_from_context(t *testing.T) {
+	var saved [][]Event
+	es := &atomicStoreMocker{
+		saveStreams: func(_ context.Context, streams [][]Event) error {
+			saved = streams
+			return nil
+		},
+	}
+
+	p1, p2 := &repoAggregate{}, &repoAggregate{}
+	_ = p1.TrackChange(p1, &repoEvent1{})
+	_ = p2.TrackChange(p2, &repoEvent1{})
+
+	uow := NewRepository(es, nil).NewUnitOfWork()
+	uow.Track(p1, p2)
+	assert.NoError(t, uow.Commit(WithCorrelationID(context.Background(), "corr")))
+
+	assert.Equal(t, "corr", saved[0][0].CorrelationID())
+	assert.Equal(t, "corr", saved[1][0].CorrelationID())
+}
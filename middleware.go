@@ -0,0 +1,472 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnauthorized is returned by authorization middlewares when the operation is denied
+	ErrUnauthorized = errors.New("operation not authorized")
+)
+
+// EventStoreMiddleware decorates an event store with cross-cutting behaviour
+type EventStoreMiddleware func(next EventStore) EventStore
+
+// RepositoryMiddleware decorates a repository with cross-cutting behaviour
+type RepositoryMiddleware func(next Repository) Repository
+
+// ChainEventStore decorates es with the middlewares, the first one being the outermost.
+// The write capabilities of es, AtomicEventStore and StreamDeleter, go through the middlewares too
+// and fail with ErrAtomicSaveNotSupported or ErrDeleteNotSupported when a middleware doesn't forward them.
+// The read capabilities, like StreamInfoReader, are used by repositories directly on es through Unwrap.
+func ChainEventStore(es EventStore, middlewares ...EventStoreMiddleware) EventStore {
+	head := es
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		head = middlewares[i](head)
+	}
+	return &chainedEventStore{EventStore: head, base: es}
+}
+
+// ChainRepository decorates r with the middlewares, the first one being the outermost
+func ChainRepository(r Repository, middlewares ...RepositoryMiddleware) Repository {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		r = middlewares[i](r)
+	}
+	return r
+}
+
+type chainedEventStore struct {
+	EventStore
+	base EventStore
+}
+
+// Unwrap returns the decorated event store
+func (c *chainedEventStore) Unwrap() EventStore {
+	return c.base
+}
+
+// SaveEventStreams saves the streams through the middlewares
+func (c *chainedEventStore) SaveEventStreams(ctx context.Context, streams [][]Event) error {
+	return saveEventStreams(ctx, c.EventStore, streams)
+}
+
+// SoftDeleteStream soft deletes the stream through the middlewares
+func (c *chainedEventStore) SoftDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	return streamDeleterOf(c.EventStore).SoftDeleteStream(ctx, aggregateID, aggregateType)
+}
+
+// HardDeleteStream hard deletes the stream through the middlewares
+func (c *chainedEventStore) HardDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	return streamDeleterOf(c.EventStore).HardDeleteStream(ctx, aggregateID, aggregateType)
+}
+
+// TruncateStream truncates the stream through the middlewares
+func (c *chainedEventStore) TruncateStream(ctx context.Context, aggregateID string, aggregateType string, beforeVersion Version) error {
+	return streamDeleterOf(c.EventStore).TruncateStream(ctx, aggregateID, aggregateType, beforeVersion)
+}
+
+// capability returns the event store, or the store it decorates, implementing the capability T
+func capability[T any](es EventStore) (T, bool) {
+	for es != nil {
+		if c, ok := es.(T); ok {
+			return c, true
+		}
+
+		u, ok := es.(interface{ Unwrap() EventStore })
+		if !ok {
+			break
+		}
+		es = u.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// saveEventStreams saves the streams atomically with es, when it's an AtomicEventStore
+func saveEventStreams(ctx context.Context, es EventStore, streams [][]Event) error {
+	store, ok := es.(AtomicEventStore)
+	if !ok {
+		return ErrAtomicSaveNotSupported
+	}
+	return store.SaveEventStreams(ctx, streams)
+}
+
+// streamDeleterOf returns es as a StreamDeleter, failing with ErrDeleteNotSupported when it isn't one
+func streamDeleterOf(es EventStore) StreamDeleter {
+	if deleter, ok := es.(StreamDeleter); ok {
+		return deleter
+	}
+	return unsupportedStreamDeleter{}
+}
+
+type unsupportedStreamDeleter struct{}
+
+func (unsupportedStreamDeleter) SoftDeleteStream(context.Context, string, string) error {
+	return ErrDeleteNotSupported
+}
+
+func (unsupportedStreamDeleter) HardDeleteStream(context.Context, string, string) error {
+	return ErrDeleteNotSupported
+}
+
+func (unsupportedStreamDeleter) TruncateStream(context.Context, string, string, Version) error {
+	return ErrDeleteNotSupported
+}
+
+// EventStoreFuncs is an EventStore made of functions, to write middlewares overriding some methods only.
+// Nil functions are forwarded to Next, except SaveEventStreamsFunc when SaveEventsFunc is set:
+// saving several streams at once would skip SaveEventsFunc so it fails with ErrAtomicSaveNotSupported.
+// Capabilities Next doesn't implement fail with ErrAtomicSaveNotSupported or ErrDeleteNotSupported.
+type EventStoreFuncs struct {
+	Next                 EventStore
+	SaveEventsFunc       func(ctx context.Context, events []Event) error
+	GetEventsFunc        func(ctx context.Context, aggregateID string, aggregateType string, afterVersion Version) ([]Event, error)
+	SaveEventStreamsFunc func(ctx context.Context, streams [][]Event) error
+	SoftDeleteStreamFunc func(ctx context.Context, aggregateID string, aggregateType string) error
+	HardDeleteStreamFunc func(ctx context.Context, aggregateID string, aggregateType string) error
+	TruncateStreamFunc   func(ctx context.Context, aggregateID string, aggregateType string, beforeVersion Version) error
+}
+
+func (f *EventStoreFuncs) SaveEvents(ctx context.Context, events []Event) error {
+	if f.SaveEventsFunc != nil {
+		return f.SaveEventsFunc(ctx, events)
+	}
+	return f.Next.SaveEvents(ctx, events)
+}
+
+func (f *EventStoreFuncs) GetEvents(ctx context.Context, aggregateID string, aggregateType string, afterVersion Version) ([]Event, error) {
+	if f.GetEventsFunc != nil {
+		return f.GetEventsFunc(ctx, aggregateID, aggregateType, afterVersion)
+	}
+	return f.Next.GetEvents(ctx, aggregateID, aggregateType, afterVersion)
+}
+
+func (f *EventStoreFuncs) SaveEventStreams(ctx context.Context, streams [][]Event) error {
+	if f.SaveEventStreamsFunc != nil {
+		return f.SaveEventStreamsFunc(ctx, streams)
+	}
+	if f.SaveEventsFunc != nil {
+		return ErrAtomicSaveNotSupported
+	}
+	return saveEventStreams(ctx, f.Next, streams)
+}
+
+func (f *EventStoreFuncs) SoftDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	if f.SoftDeleteStreamFunc != nil {
+		return f.SoftDeleteStreamFunc(ctx, aggregateID, aggregateType)
+	}
+	return streamDeleterOf(f.Next).SoftDeleteStream(ctx, aggregateID, aggregateType)
+}
+
+func (f *EventStoreFuncs) HardDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error {
+	if f.HardDeleteStreamFunc != nil {
+		return f.HardDeleteStreamFunc(ctx, aggregateID, aggregateType)
+	}
+	return streamDeleterOf(f.Next).HardDeleteStream(ctx, aggregateID, aggregateType)
+}
+
+func (f *EventStoreFuncs) TruncateStream(ctx context.Context, aggregateID string, aggregateType string, beforeVersion Version) error {
+	if f.TruncateStreamFunc != nil {
+		return f.TruncateStreamFunc(ctx, aggregateID, aggregateType, beforeVersion)
+	}
+	return streamDeleterOf(f.Next).TruncateStream(ctx, aggregateID, aggregateType, beforeVersion)
+}
+
+func (f *EventStoreFuncs) Close() error {
+	return f.Next.Close()
+}
+
+// EnrichMetadata adds the metadata returned by f to the saved events,
+// keeping the values the events already hold for the same keys.
+func EnrichMetadata(f MetadataEnricher) EventStoreMiddleware {
+	return func(next EventStore) EventStore {
+		return &EventStoreFuncs{
+			Next: next,
+			SaveEventsFunc: func(ctx context.Context, events []Event) error {
+				return next.SaveEvents(ctx, enrichEvents(events, f(ctx)))
+			},
+			SaveEventStreamsFunc: func(ctx context.Context, streams [][]Event) error {
+				extra := f(ctx)
+				enriched := make([][]Event, len(streams))
+				for i := range streams {
+					enriched[i] = enrichEvents(streams[i], extra)
+				}
+				return saveEventStreams(ctx, next, enriched)
+			},
+		}
+	}
+}
+
+// enrichEvents returns a copy of the events with the extra metadata merged in
+func enrichEvents(events []Event, extra map[string]interface{}) []Event {
+	if len(extra) == 0 {
+		return events
+	}
+
+	enriched := make([]Event, len(events))
+	for i := range events {
+		enriched[i] = events[i]
+		enriched[i].Metadata = mergeMetadata(events[i].Metadata, extra)
+	}
+	return enriched
+}
+
+// AuditEventStore logs at info level the events saved and read and the streams deleted, and at warn level the failures
+func AuditEventStore(logger Logger) EventStoreMiddleware {
+	return func(next EventStore) EventStore {
+		return &EventStoreFuncs{
+			Next: next,
+			SaveEventsFunc: func(ctx context.Context, events []Event) error {
+				err := next.SaveEvents(ctx, events)
+				auditSave(logger, events, err)
+				return err
+			},
+			SaveEventStreamsFunc: func(ctx context.Context, streams [][]Event) error {
+				err := saveEventStreams(ctx, next, streams)
+				for i := range streams {
+					auditSave(logger, streams[i], err)
+				}
+				return err
+			},
+			SoftDeleteStreamFunc: func(ctx context.Context, aggregateID string, aggregateType string) error {
+				err := streamDeleterOf(next).SoftDeleteStream(ctx, aggregateID, aggregateType)
+				auditDelete(logger, "soft deleted", aggregateID, aggregateType, err)
+				return err
+			},
+			HardDeleteStreamFunc: func(ctx context.Context, aggregateID string, aggregateType string) error {
+				err := streamDeleterOf(next).HardDeleteStream(ctx, aggregateID, aggregateType)
+				auditDelete(logger, "hard deleted", aggregateID, aggregateType, err)
+				return err
+			},
+			TruncateStreamFunc: func(ctx context.Context, aggregateID string, aggregateType string, beforeVersion Version) error {
+				err := streamDeleterOf(next).TruncateStream(ctx, aggregateID, aggregateType, beforeVersion)
+				auditDelete(logger, "truncated", aggregateID, aggregateType, err)
+				return err
+			},
+			GetEventsFunc: func(ctx context.Context, aggregateID string, aggregateType string, afterVersion Version) ([]Event, error) {
+				events, err := next.GetEvents(ctx, aggregateID, aggregateType, afterVersion)
+				fields := []interface{}{
+					LogKeyAggregateType, aggregateType,
+					LogKeyAggregateID, aggregateID,
+					LogKeyVersion, afterVersion,
+				}
+				if err != nil && !errors.Is(err, ErrNoEvents) {
+					logger.Warn("audit: reading events failed", append(fields, LogKeyError, err)...)
+				} else {
+					logger.Info("audit: events read", append(fields, LogKeyEvents, len(events))...)
+				}
+				return events, err
+			},
+		}
+	}
+}
+
+func auditSave(logger Logger, events []Event, err error) {
+	if len(events) == 0 {
+		return
+	}
+
+	last := events[len(events)-1]
+	fields := []interface{}{
+		LogKeyAggregateType, last.AggregateType,
+		LogKeyAggregateID, last.AggregateID,
+		LogKeyVersion, last.Version,
+		LogKeyEvents, len(events),
+	}
+	if err != nil {
+		logger.Warn("audit: saving events failed", append(fields, LogKeyError, err)...)
+	} else {
+		logger.Info("audit: events saved", fields...)
+	}
+}
+
+func auditDelete(logger Logger, action string, aggregateID string, aggregateType string, err error) {
+	fields := []interface{}{
+		LogKeyAggregateType, aggregateType,
+		LogKeyAggregateID, aggregateID,
+	}
+	if err != nil {
+		logger.Warn("audit: stream not "+action, append(fields, LogKeyError, err)...)
+	} else {
+		logger.Info("audit: stream "+action, fields...)
+	}
+}
+
+// Operations authorized by AuthorizeRepository
+const (
+	OperationGet    = "get"
+	OperationSave   = "save"
+	OperationDelete = "delete"
+)
+
+// AuthorizeRepository checks with allow that the operation can be made on the aggregate before running it.
+// The optional operations of the decorated repository are forwarded, the ones it doesn't implement
+// fail with ErrOperationNotSupported.
+// Reading the aggregate or its stream information is an OperationGet, saving, appending to its stream,
+// committing it in a UnitOfWork or saving its snapshot an OperationSave, deleting or truncating it an OperationDelete.
+// The error of allow is returned wrapped in ErrUnauthorized.
+func AuthorizeRepository(allow func(ctx context.Context, operation string, aggregateType string, aggregateID string) error) RepositoryMiddleware {
+	return func(next Repository) Repository {
+		return &authorizedRepository{Repository: next, allow: allow}
+	}
+}
+
+type authorizedRepository struct {
+	Repository
+	allow func(ctx context.Context, operation string, aggregateType string, aggregateID string) error
+}
+
+func (a *authorizedRepository) Get(ctx context.Context, aggregateID string, aggregate Aggregate) error {
+	if err := a.check(ctx, OperationGet, formatAggregatePathType(aggregate), aggregateID); err != nil {
+		return err
+	}
+	return a.Repository.Get(ctx, aggregateID, aggregate)
+}
+
+func (a *authorizedRepository) Save(ctx context.Context, aggregate Aggregate) error {
+	if err := a.check(ctx, OperationSave, formatAggregatePathType(aggregate), aggregate.Root().ID()); err != nil {
+		return err
+	}
+	return a.Repository.Save(ctx, aggregate)
+}
+
+func (a *authorizedRepository) Reload(ctx context.Context, aggregate Aggregate) error {
+	r, ok := a.Repository.(Reloader)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationGet, formatAggregatePathType(aggregate), aggregate.Root().ID()); err != nil {
+		return err
+	}
+	return r.Reload(ctx, aggregate)
+}
+
+func (a *authorizedRepository) Exists(ctx context.Context, aggregateID string, aggregate Aggregate) (bool, error) {
+	r, ok := a.Repository.(AggregateInspector)
+	if !ok {
+		return false, ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationGet, formatAggregatePathType(aggregate), aggregateID); err != nil {
+		return false, err
+	}
+	return r.Exists(ctx, aggregateID, aggregate)
+}
+
+func (a *authorizedRepository) CurrentVersion(ctx context.Context, aggregateID string, aggregate Aggregate) (Version, error) {
+	r, ok := a.Repository.(AggregateInspector)
+	if !ok {
+		return 0, ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationGet, formatAggregatePathType(aggregate), aggregateID); err != nil {
+		return 0, err
+	}
+	return r.CurrentVersion(ctx, aggregateID, aggregate)
+}
+
+func (a *authorizedRepository) Delete(ctx context.Context, aggregateID string, aggregate Aggregate) error {
+	r, ok := a.Repository.(AggregateDeleter)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationDelete, formatAggregatePathType(aggregate), aggregateID); err != nil {
+		return err
+	}
+	return r.Delete(ctx, aggregateID, aggregate)
+}
+
+func (a *authorizedRepository) HardDelete(ctx context.Context, aggregateID string, aggregate Aggregate) error {
+	r, ok := a.Repository.(AggregateDeleter)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationDelete, formatAggregatePathType(aggregate), aggregateID); err != nil {
+		return err
+	}
+	return r.HardDelete(ctx, aggregateID, aggregate)
+}
+
+func (a *authorizedRepository) Truncate(ctx context.Context, aggregateID string, aggregate Aggregate, beforeVersion Version) error {
+	r, ok := a.Repository.(AggregateDeleter)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationDelete, formatAggregatePathType(aggregate), aggregateID); err != nil {
+		return err
+	}
+	return r.Truncate(ctx, aggregateID, aggregate, beforeVersion)
+}
+
+func (a *authorizedRepository) AppendToStream(ctx context.Context, aggregateType, aggregateID string, expected ExpectedVersion, events []Event) error {
+	r, ok := a.Repository.(StreamAppender)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationSave, aggregateType, aggregateID); err != nil {
+		return err
+	}
+	return r.AppendToStream(ctx, aggregateType, aggregateID, expected, events)
+}
+
+func (a *authorizedRepository) SaveSnapshot(ctx context.Context, aggregate Aggregate) error {
+	r, ok := a.Repository.(SnapshotSaver)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	if err := a.check(ctx, OperationSave, formatAggregatePathType(aggregate), aggregate.Root().ID()); err != nil {
+		return err
+	}
+	return r.SaveSnapshot(ctx, aggregate)
+}
+
+// NewUnitOfWork returns a UnitOfWork authorizing the save of every changed aggregate when committing
+func (a *authorizedRepository) NewUnitOfWork() *UnitOfWork {
+	uow := unitOfWorkOf(a.Repository)
+	uow.AddCommitHook(func(ctx context.Context, aggregate Aggregate) error {
+		return a.check(ctx, OperationSave, formatAggregatePathType(aggregate), aggregate.Root().ID())
+	})
+	return uow
+}
+
+func (a *authorizedRepository) Bind(aggregate Aggregate) {
+	if r, ok := a.Repository.(Binder); ok {
+		r.Bind(aggregate)
+	}
+}
+
+func (a *authorizedRepository) check(ctx context.Context, operation string, aggregateType string, aggregateID string) error {
+	if err := a.allow(ctx, operation, aggregateType, aggregateID); err != nil {
+		if errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	return nil
+}
+
+// unitOfWorkOf returns a new unit of work of r, failing to commit with ErrOperationNotSupported when it isn't a UnitOfWorkFactory
+func unitOfWorkOf(r Repository) *UnitOfWork {
+	if factory, ok := r.(UnitOfWorkFactory); ok {
+		return factory.NewUnitOfWork()
+	}
+
+	uow := &UnitOfWork{}
+	uow.AddCommitHook(func(context.Context, Aggregate) error {
+		return ErrOperationNotSupported
+	})
+	return uow
+}
+
+// mergeMetadata returns a copy of metadata with the extra values it doesn't hold
+func mergeMetadata(metadata EventMetadata, extra map[string]interface{}) EventMetadata {
+	merged := make(EventMetadata, len(metadata)+len(extra))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	return merged
+}
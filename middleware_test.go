@@ -0,0 +1,301 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ChainEventStore_should_apply_middlewares_in_order(t *testing.T) {
+	var calls []string
+	trace := func(name string) EventStoreMiddleware {
+		return func(next EventStore) EventStore {
+			return &EventStoreFuncs{
+				Next: next,
+				SaveEventsFunc: func(ctx context.Context, events []Event) error {
+					calls = append(calls, name)
+					return next.SaveEvents(ctx, events)
+				},
+			}
+		}
+	}
+
+	es := &eventStoreMocker{
+		save: func(context.Context, []Event) error {
+			calls = append(calls, "store")
+			return nil
+		},
+		get: func(context.Context, string, string, Version) ([]Event, error) {
+			calls = append(calls, "get")
+			return nil, nil
+		},
+	}
+
+	chained := ChainEventStore(es, trace("first"), trace("second"))
+	assert.NoError(t, chained.SaveEvents(context.Background(), nil))
+	_, _ = chained.GetEvents(context.Background(), "1", "T", 0)
+	assert.Equal(t, []string{"first", "second", "store", "get"}, calls)
+}
+
+func Test_ChainEventStore_should_keep_store_capabilities(t *testing.T) {
+	es := &streamDeleterMocker{}
+	logger := &loggerMocker{}
+	repo := NewRepository(ChainEventStore(es, AuditEventStore(logger)), nil)
+
+	assert.NoError(t, repo.Delete(context.Background(), "1", &repoAggregate{}))
+	assert.Len(t, es.calls, 1)
+	assert.Equal(t, []string{"audit: stream soft deleted"}, logger.messages(), "deletes go through the middlewares")
+}
+
+func Test_ChainEventStore_should_save_event_streams_through_middlewares(t *testing.T) {
+	var saved [][]Event
+	es := &atomicStoreMocker{
+		saveStreams: func(_ context.Context, streams [][]Event) error {
+			saved = streams
+			return nil
+		},
+	}
+
+	enrich := EnrichMetadata(func(context.Context) map[string]interface{} {
+		return map[string]interface{}{"tenant": "acme"}
+	})
+	repo := NewRepository(ChainEventStore(es, enrich), nil)
+
+	a1, a2 := &repoAggregate{}, &repoAggregate{}
+	_ = a1.TrackChange(a1, &repoEvent1{})
+	_ = a2.TrackChange(a2, &repoEvent1{})
+
+	uow := repo.NewUnitOfWork()
+	uow.Track(a1, a2)
+	assert.NoError(t, uow.Commit(context.Background()))
+	assert.Len(t, saved, 2)
+	assert.Equal(t, "acme", saved[0][0].Metadata["tenant"])
+	assert.Equal(t, "acme", saved[1][0].Metadata["tenant"])
+}
+
+func Test_ChainEventStore_should_not_bypass_middlewares_without_capability_hooks(t *testing.T) {
+	es := &atomicStoreMocker{
+		saveStreams: func(context.Context, [][]Event) error {
+			t.Fatal("the middleware must not be bypassed")
+			return nil
+		},
+	}
+
+	skipped := func(next EventStore) EventStore {
+		return &EventStoreFuncs{Next: next, SaveEventsFunc: next.SaveEvents}
+	}
+	repo := NewRepository(ChainEventStore(es, skipped), nil)
+
+	a1, a2 := &repoAggregate{}, &repoAggregate{}
+	_ = a1.TrackChange(a1, &repoEvent1{})
+	_ = a2.TrackChange(a2, &repoEvent1{})
+
+	uow := repo.NewUnitOfWork()
+	uow.Track(a1, a2)
+	assert.ErrorIs(t, uow.Commit(context.Background()), ErrAtomicSaveNotSupported)
+
+	chained := NewRepository(ChainEventStore(&eventStoreMocker{}, skipped), nil)
+	assert.ErrorIs(t, chained.Delete(context.Background(), "1", &repoAggregate{}), ErrDeleteNotSupported)
+}
+
+func Test_EnrichMetadata_should_add_metadata_without_overriding(t *testing.T) {
+	var saved []Event
+	es := &eventStoreMocker{
+		save: func(_ context.Context, events []Event) error {
+			saved = events
+			return nil
+		},
+	}
+
+	enrich := EnrichMetadata(func(context.Context) map[string]interface{} {
+		return map[string]interface{}{"tenant": "acme", "source": "api"}
+	})
+
+	events := []Event{{Data: &repoEvent1{}, Metadata: EventMetadata{"source": "import"}}, {Data: &repoEvent1{}}}
+	assert.NoError(t, ChainEventStore(es, enrich).SaveEvents(context.Background(), events))
+
+	assert.Equal(t, EventMetadata{"tenant": "acme", "source": "import"}, saved[0].Metadata)
+	assert.Equal(t, EventMetadata{"tenant": "acme", "source": "api"}, saved[1].Metadata)
+	assert.Equal(t, EventMetadata{"source": "import"}, events[0].Metadata, "the events of the caller are not changed")
+}
+
+func Test_AuditEventStore_should_log_saves_and_reads(t *testing.T) {
+	logger := &loggerMocker{}
+	errSave := errors.New("save failed")
+	es := &eventStoreMocker{
+		save: func(context.Context, []Event) error { return errSave },
+		get: func(context.Context, string, string, Version) ([]Event, error) {
+			return []Event{{}, {}}, nil
+		},
+	}
+
+	audited := ChainEventStore(es, AuditEventStore(logger))
+	assert.ErrorIs(t, audited.SaveEvents(context.Background(), []Event{{AggregateID: "1", Version: 4}}), errSave)
+	_, err := audited.GetEvents(context.Background(), "1", "T", 2)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"audit: saving events failed", "audit: events read"}, logger.messages())
+	assert.Equal(t, "warn", logger.entries[0].level)
+	assert.Equal(t, Version(4), logger.entries[0].field(LogKeyVersion))
+	assert.Equal(t, 2, logger.entries[1].field(LogKeyEvents))
+}
+
+func Test_AuthorizeRepository(t *testing.T) {
+	es := &eventStoreMocker{
+		save: func(context.Context, []Event) error { return nil },
+		get: func(_ context.Context, id string, _ string, _ Version) ([]Event, error) {
+			return []Event{{AggregateID: id, Version: 1, Data: &repoEvent1{}}}, nil
+		},
+	}
+
+	type user struct{}
+	allow := func(ctx context.Context, operation string, aggregateType string, aggregateID string) error {
+		assert.Equal(t, formatAggregatePathType(&repoAggregate{}), aggregateType)
+		if operation == OperationSave && ctx.Value(user{}) == nil {
+			return errors.New("anonymous users can't save")
+		}
+		return nil
+	}
+
+	repo := ChainRepository(NewRepository(es, nil), AuthorizeRepository(allow))
+	assert.NoError(t, repo.Get(context.Background(), "1", &repoAggregate{}))
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	err := repo.Save(context.Background(), agg)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.EqualError(t, err, "operation not authorized: anonymous users can't save")
+
+	assert.NoError(t, repo.Save(context.WithValue(context.Background(), user{}, "jane"), agg))
+}
+
+func Test_AuthorizeRepository_should_authorize_every_operation(t *testing.T) {
+	es := &streamDeleterMocker{eventStoreMocker: eventStoreMocker{
+		save: func(context.Context, []Event) error { return nil },
+		get: func(_ context.Context, id string, _ string, _ Version) ([]Event, error) {
+			return []Event{{AggregateID: id, Version: 1, Data: &repoEvent1{}}}, nil
+		},
+	}}
+
+	var operations []string
+	deny := func(_ context.Context, operation string, _ string, _ string) error {
+		operations = append(operations, operation)
+		return errors.New("denied")
+	}
+
+	ctx := context.Background()
+	repo := ChainRepository(NewRepository(es, nil), AuthorizeRepository(deny))
+	agg := &repoAggregate{}
+	_ = agg.SetID("1")
+	_ = agg.TrackChange(agg, &repoEvent1{})
+
+	_, err := repo.(AggregateInspector).Exists(ctx, "1", agg)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = repo.(AggregateInspector).CurrentVersion(ctx, "1", agg)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorIs(t, repo.(Reloader).Reload(ctx, agg), ErrUnauthorized)
+	assert.ErrorIs(t, repo.(AggregateDeleter).Delete(ctx, "1", agg), ErrUnauthorized)
+	assert.ErrorIs(t, repo.(AggregateDeleter).HardDelete(ctx, "1", agg), ErrUnauthorized)
+	assert.ErrorIs(t, repo.(AggregateDeleter).Truncate(ctx, "1", agg, 1), ErrUnauthorized)
+	assert.ErrorIs(t, repo.(StreamAppender).AppendToStream(ctx, "T", "1", ExpectAny, []Event{{Data: &repoEvent1{}}}), ErrUnauthorized)
+	assert.ErrorIs(t, repo.(SnapshotSaver).SaveSnapshot(ctx, agg), ErrUnauthorized)
+
+	uow := repo.(UnitOfWorkFactory).NewUnitOfWork()
+	uow.Track(agg)
+	assert.ErrorIs(t, uow.Commit(ctx), ErrUnauthorized)
+
+	typed := NewTypedRepository(repo, newRepoAggregate)
+	_, err = typed.Load(ctx, "1")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorIs(t, typed.Save(ctx, agg), ErrUnauthorized)
+
+	assert.Equal(t, []string{
+		OperationGet, OperationGet, OperationGet,
+		OperationDelete, OperationDelete, OperationDelete,
+		OperationSave, OperationSave, OperationSave,
+		OperationGet, OperationSave,
+	}, operations)
+	assert.Empty(t, es.calls)
+	assert.True(t, agg.HasUnsavedEvents())
+}
+
+func Test_AuthorizeRepository_should_not_support_operations_the_repository_does_not(t *testing.T) {
+	allow := func(context.Context, string, string, string) error { return nil }
+	repo := ChainRepository(&repositoryMocker{}, AuthorizeRepository(allow))
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+
+	assert.ErrorIs(t, repo.(AggregateDeleter).Delete(context.Background(), "1", agg), ErrOperationNotSupported)
+
+	uow := repo.(UnitOfWorkFactory).NewUnitOfWork()
+	uow.Track(agg)
+	assert.ErrorIs(t, uow.Commit(context.Background()), ErrOperationNotSupported)
+
+	_, err := NewTypedRepository(&repositoryMocker{}, newRepoAggregate).Exists(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrOperationNotSupported)
+}
+
+func Test_UnitOfWork_Commit_should_call_the_hooks_of_every_middleware(t *testing.T) {
+	var calls []string
+	hook := func(name string) RepositoryMiddleware {
+		return func(next Repository) Repository {
+			return &hookedRepository{Repository: next, hook: func(_ context.Context, aggregate Aggregate) error {
+				calls = append(calls, name+":"+aggregate.Root().ID())
+				return nil
+			}}
+		}
+	}
+
+	es := &eventStoreMocker{save: func(context.Context, []Event) error { return nil }}
+	repo := ChainRepository(NewRepository(es, nil), hook("first"), hook("second"))
+
+	agg := &repoAggregate{}
+	_ = agg.SetID("1")
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	uow := repo.(UnitOfWorkFactory).NewUnitOfWork()
+	uow.Track(agg)
+
+	assert.NoError(t, uow.Commit(context.Background()))
+	assert.Equal(t, []string{"first:1", "second:1"}, calls)
+	assert.False(t, agg.HasUnsavedEvents())
+}
+
+func Test_ChainRepository_should_apply_middlewares_in_order(t *testing.T) {
+	var calls []string
+	trace := func(name string) RepositoryMiddleware {
+		return AuthorizeRepository(func(context.Context, string, string, string) error {
+			calls = append(calls, name)
+			return nil
+		})
+	}
+
+	es := &eventStoreMocker{save: func(context.Context, []Event) error { return nil }}
+	repo := ChainRepository(NewRepository(es, nil), trace("first"), trace("second"))
+
+	agg := &repoAggregate{}
+	_ = agg.TrackChange(agg, &repoEvent1{})
+	assert.NoError(t, repo.Save(context.Background(), agg))
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+// region mocks
+
+// repositoryMocker implements Repository only, none of the optional operations
+type repositoryMocker struct {
+	Repository
+}
+
+type hookedRepository struct {
+	Repository
+	hook CommitHook
+}
+
+func (h *hookedRepository) NewUnitOfWork() *UnitOfWork {
+	uow := h.Repository.(UnitOfWorkFactory).NewUnitOfWork()
+	uow.AddCommitHook(h.hook)
+	return uow
+}
+
+// endregion
@@ -0,0 +1,46 @@
+package historia
+
+import (
+	"context"
+)
+
+// Observer is notified by repositories, snappers and event streams at key points of their work,
+// to trace and measure them. Hooks returning a function are called when the work starts,
+// the returned function when it ends with the resulting error.
+type Observer interface {
+	// Load is called when the repository starts loading an aggregate
+	Load(ctx context.Context, aggregateType, aggregateID string) (context.Context, func(err error))
+
+	// EventsRead is called after events of an aggregate were read from the event store
+	EventsRead(ctx context.Context, aggregateType, aggregateID string, count int)
+
+	// Snapshot is called when the snapper looked up the snapshot of an aggregate, hit reporting if it was found
+	Snapshot(ctx context.Context, aggregateType, aggregateID string, hit bool)
+
+	// Save is called when the repository starts saving the events of an aggregate
+	Save(ctx context.Context, aggregateType, aggregateID string, count int) (context.Context, func(err error))
+
+	// Publish is called when a subscriber starts handling an event
+	Publish(ctx context.Context, event Event) (context.Context, func(err error))
+}
+
+// NopObserver is an Observer doing nothing, the default one
+type NopObserver struct{}
+
+func (NopObserver) Load(ctx context.Context, _, _ string) (context.Context, func(err error)) {
+	return ctx, nopEnd
+}
+
+func (NopObserver) EventsRead(context.Context, string, string, int) {}
+
+func (NopObserver) Snapshot(context.Context, string, string, bool) {}
+
+func (NopObserver) Save(ctx context.Context, _, _ string, _ int) (context.Context, func(err error)) {
+	return ctx, nopEnd
+}
+
+func (NopObserver) Publish(ctx context.Context, _ Event) (context.Context, func(err error)) {
+	return ctx, nopEnd
+}
+
+func nopEnd(error) {}
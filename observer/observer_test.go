@@ -0,0 +1,189 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	hi "github.com/bansukai/historia"
+	esmemory "github.com/bansukai/historia/eventstore/memory"
+	ssmemory "github.com/bansukai/historia/snapshot/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Recorder_should_record_repository_work(t *testing.T) {
+	ctx := context.Background()
+	rec := NewRecorder()
+	snapper := hi.NewSnapper(ssmemory.New(), hi.NewJSONMarshal(), hi.WithSnapperObserver(rec))
+	repo := hi.NewRepository(esmemory.New(), snapper, hi.WithRepositoryObserver(rec))
+
+	repo.SubscriberSpecificEvent(func(context.Context, hi.Event) error { return nil }, &counted{}).Subscribe()
+
+	c := &counter{}
+	assert.NoError(t, c.TrackChange(c, &incremented{}))
+	assert.NoError(t, c.TrackChange(c, &incremented{}))
+	assert.NoError(t, repo.Save(ctx, c))
+
+	saves := rec.CallsOf(HookSave)
+	assert.Len(t, saves, 1)
+	assert.Equal(t, 2, saves[0].Count)
+	assert.Equal(t, hi.AggregateTypeOf(c), saves[0].AggregateType)
+	assert.NoError(t, saves[0].Err)
+	assert.Empty(t, rec.CallsOf(HookPublish), "only subscribed handlers are observed")
+
+	rec.Reset()
+	loaded := &counter{}
+	assert.NoError(t, repo.Get(ctx, c.ID(), loaded))
+	assert.Equal(t, []Hook{HookSnapshot, HookEventsRead, HookLoad}, hooks(rec.Calls()))
+	assert.False(t, rec.CallsOf(HookSnapshot)[0].Hit)
+	assert.Equal(t, 2, rec.CallsOf(HookEventsRead)[0].Count)
+
+	assert.NoError(t, repo.SaveSnapshot(ctx, loaded))
+	rec.Reset()
+	assert.NoError(t, repo.Get(ctx, c.ID(), &counter{}))
+	assert.True(t, rec.CallsOf(HookSnapshot)[0].Hit)
+	assert.Equal(t, 0, rec.CallsOf(HookEventsRead)[0].Count)
+
+	rec.Reset()
+	assert.ErrorIs(t, repo.Get(ctx, "unknown", &counter{}), hi.ErrAggregateNotFound)
+	assert.ErrorIs(t, rec.CallsOf(HookLoad)[0].Err, hi.ErrAggregateNotFound)
+}
+
+func Test_Recorder_should_record_publish_per_subscriber(t *testing.T) {
+	rec := NewRecorder()
+	now := time.Now()
+	rec.nowFunc = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	stream := hi.NewEventStream(hi.WithEventStreamObserver(rec))
+	stream.SubscriberAll(func(context.Context, hi.Event) error { return nil }).Subscribe()
+	stream.SubscriberAggregateType(func(context.Context, hi.Event) error { return nil }, &counter{}).Subscribe()
+
+	c := &counter{}
+	assert.NoError(t, c.TrackChange(c, &incremented{}))
+	assert.NoError(t, stream.Update(context.Background(), c, c.Events()))
+
+	publishes := rec.CallsOf(HookPublish)
+	assert.Len(t, publishes, 2)
+	assert.Equal(t, c.Events()[0], publishes[0].Event)
+	assert.Equal(t, time.Millisecond, publishes[0].Duration)
+}
+
+func Test_OTel_should_trace_and_measure(t *testing.T) {
+	tracer, meter := &tracerMocker{}, &meterMocker{}
+	o := NewOTel(tracer, meter)
+	ctx := context.Background()
+
+	errSave := errors.New("save failed")
+	_, end := o.Save(ctx, "T", "1", 3)
+	assert.Zero(t, meter.counters[MetricEventsSaved])
+	end(errSave)
+	assert.Zero(t, meter.counters[MetricEventsSaved])
+	_, end = o.Save(ctx, "T", "1", 2)
+	end(nil)
+	o.Snapshot(ctx, "T", "1", true)
+	o.Snapshot(ctx, "T", "2", false)
+	o.EventsRead(ctx, "T", "1", 5)
+
+	_, end = o.Publish(ctx, hi.Event{AggregateType: "T", AggregateID: "1", Data: &incremented{}})
+	end(nil)
+
+	assert.Len(t, tracer.spans, 3)
+	assert.Equal(t, SpanSave, tracer.spans[0].name)
+	assert.Contains(t, tracer.spans[0].attrs, Attribute{AttrEventCount, 3})
+	assert.Equal(t, []error{errSave}, tracer.spans[0].errs)
+	assert.True(t, tracer.spans[0].ended)
+	assert.Contains(t, tracer.spans[2].attrs, Attribute{AttrEventType, "incremented"})
+
+	assert.Equal(t, int64(2), meter.counters[MetricEventsSaved])
+	assert.Equal(t, int64(5), meter.counters[MetricEventsRead])
+	assert.Equal(t, int64(1), meter.counters[MetricSnapshotHits])
+	assert.Equal(t, int64(1), meter.counters[MetricSnapshotMisses])
+	assert.Len(t, meter.histograms[MetricSaveDuration], 2)
+	assert.Len(t, meter.histograms[MetricPublishDuration], 1)
+}
+
+func Test_OTel_should_work_without_tracer_or_meter(t *testing.T) {
+	o := NewOTel(nil, nil)
+	ctx, end := o.Load(context.Background(), "T", "1")
+	assert.NotNil(t, ctx)
+	end(nil)
+	o.EventsRead(ctx, "T", "1", 1)
+}
+
+func hooks(calls []Call) []Hook {
+	result := make([]Hook, len(calls))
+	for i := range calls {
+		result[i] = calls[i].Hook
+	}
+	return result
+}
+
+// region mocks
+
+type counter struct {
+	hi.AggregateBase
+	count int
+}
+
+func (c *counter) Transition(evt hi.Event) {
+	if _, ok := evt.Data.(*incremented); ok {
+		c.count++
+	}
+}
+
+func (c *counter) TakeSnapshot() hi.SnapshotBody {
+	return map[string]int{"count": c.count}
+}
+
+func (c *counter) ApplySnapshot(hi.SnapshotBody) error {
+	return nil
+}
+
+type incremented struct{}
+
+type counted struct{}
+
+type span struct {
+	name  string
+	attrs []Attribute
+	errs  []error
+	ended bool
+}
+
+func (s *span) RecordError(err error) { s.errs = append(s.errs, err) }
+func (s *span) End()                  { s.ended = true }
+
+type tracerMocker struct {
+	spans []*span
+}
+
+func (t *tracerMocker) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	s := &span{name: name, attrs: attrs}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+type meterMocker struct {
+	counters   map[string]int64
+	histograms map[string][]float64
+}
+
+func (m *meterMocker) Add(_ context.Context, name string, value int64, _ ...Attribute) {
+	if m.counters == nil {
+		m.counters = map[string]int64{}
+	}
+	m.counters[name] += value
+}
+
+func (m *meterMocker) Record(_ context.Context, name string, value float64, _ ...Attribute) {
+	if m.histograms == nil {
+		m.histograms = map[string][]float64{}
+	}
+	m.histograms[name] = append(m.histograms[name], value)
+}
+
+// endregion
@@ -0,0 +1,143 @@
+package observer
+
+import (
+	"context"
+	"time"
+
+	hi "github.com/bansukai/historia"
+)
+
+// Names of the spans and metrics of the OTel observer
+const (
+	SpanLoad    = "historia.Load"
+	SpanSave    = "historia.Save"
+	SpanPublish = "historia.Publish"
+
+	MetricLoadDuration    = "historia.load.duration"
+	MetricSaveDuration    = "historia.save.duration"
+	MetricPublishDuration = "historia.publish.duration"
+	MetricEventsRead      = "historia.events.read"
+	MetricEventsSaved     = "historia.events.saved"
+	MetricSnapshotHits    = "historia.snapshot.hits"
+	MetricSnapshotMisses  = "historia.snapshot.misses"
+
+	AttrAggregateType = "historia.aggregate_type"
+	AttrAggregateID   = "historia.aggregate_id"
+	AttrEventType     = "historia.event_type"
+	AttrEventCount    = "historia.event_count"
+)
+
+// Attribute is a key value pair describing a span or a measurement
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts spans, the subset of an OpenTelemetry tracer used by the OTel observer
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is the subset of an OpenTelemetry span used by the OTel observer
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+// Meter records measurements, the subset of OpenTelemetry counters and histograms used by the OTel observer
+type Meter interface {
+	// Add adds value to the counter name
+	Add(ctx context.Context, name string, value int64, attrs ...Attribute)
+
+	// Record records value in the histogram name
+	Record(ctx context.Context, name string, value float64, attrs ...Attribute)
+}
+
+// NewOTel returns an Observer tracing loads, saves and publishes as spans, and measuring their durations
+// in milliseconds, the number of events read and saved, and the snapshot hits and misses.
+// Either the tracer or the meter can be nil.
+func NewOTel(tracer Tracer, meter Meter) *OTel {
+	return &OTel{
+		tracer:  tracer,
+		meter:   meter,
+		nowFunc: time.Now,
+	}
+}
+
+// OTel is an Observer reporting to OpenTelemetry-style tracers and meters,
+// thin wrappers adapt the OpenTelemetry SDK to its interfaces.
+type OTel struct {
+	tracer  Tracer
+	meter   Meter
+	nowFunc func() time.Time
+}
+
+func (o *OTel) Load(ctx context.Context, aggregateType, aggregateID string) (context.Context, func(err error)) {
+	return o.start(ctx, SpanLoad, MetricLoadDuration, aggregateAttributes(aggregateType, aggregateID))
+}
+
+func (o *OTel) EventsRead(ctx context.Context, aggregateType, aggregateID string, count int) {
+	o.add(ctx, MetricEventsRead, int64(count), Attribute{AttrAggregateType, aggregateType})
+}
+
+func (o *OTel) Snapshot(ctx context.Context, aggregateType, aggregateID string, hit bool) {
+	metric := MetricSnapshotMisses
+	if hit {
+		metric = MetricSnapshotHits
+	}
+	o.add(ctx, metric, 1, Attribute{AttrAggregateType, aggregateType})
+}
+
+func (o *OTel) Save(ctx context.Context, aggregateType, aggregateID string, count int) (context.Context, func(err error)) {
+	attrs := append(aggregateAttributes(aggregateType, aggregateID), Attribute{AttrEventCount, count})
+	ctx, end := o.start(ctx, SpanSave, MetricSaveDuration, attrs)
+	return ctx, func(err error) {
+		end(err)
+		// only the events actually stored are counted
+		if err == nil {
+			o.add(ctx, MetricEventsSaved, int64(count), Attribute{AttrAggregateType, aggregateType})
+		}
+	}
+}
+
+func (o *OTel) Publish(ctx context.Context, event hi.Event) (context.Context, func(err error)) {
+	attrs := append(aggregateAttributes(event.AggregateType, event.AggregateID), Attribute{AttrEventType, event.Reason()})
+	return o.start(ctx, SpanPublish, MetricPublishDuration, attrs)
+}
+
+// start starts a span and returns the function ending it and recording its duration
+func (o *OTel) start(ctx context.Context, spanName, metric string, attrs []Attribute) (context.Context, func(err error)) {
+	var span Span
+	if o.tracer != nil {
+		ctx, span = o.tracer.Start(ctx, spanName, attrs...)
+	}
+
+	started := o.nowFunc()
+	return ctx, func(err error) {
+		if o.meter != nil {
+			// the aggregate id isn't a measurement attribute, it has too many values
+			ms := float64(o.nowFunc().Sub(started)) / float64(time.Millisecond)
+			o.meter.Record(ctx, metric, ms, attrs[0])
+		}
+
+		if span != nil {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}
+	}
+}
+
+func (o *OTel) add(ctx context.Context, name string, value int64, attrs ...Attribute) {
+	if o.meter != nil {
+		o.meter.Add(ctx, name, value, attrs...)
+	}
+}
+
+func aggregateAttributes(aggregateType, aggregateID string) []Attribute {
+	return []Attribute{
+		{AttrAggregateType, aggregateType},
+		{AttrAggregateID, aggregateID},
+	}
+}
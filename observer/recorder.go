@@ -0,0 +1,122 @@
+// Package observer holds ready-made historia.Observer implementations:
+// an adapter to OpenTelemetry-style tracers and meters, and a recorder for tests.
+package observer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	hi "github.com/bansukai/historia"
+)
+
+// Hook names the Observer method a call was made to
+type Hook string
+
+const (
+	HookLoad       Hook = "Load"
+	HookEventsRead Hook = "EventsRead"
+	HookSnapshot   Hook = "Snapshot"
+	HookSave       Hook = "Save"
+	HookPublish    Hook = "Publish"
+)
+
+// Call is a call to an Observer recorded once the observed work ended
+type Call struct {
+	Hook          Hook
+	AggregateType string
+	AggregateID   string
+
+	// Count is the number of events read or saved
+	Count int
+
+	// Hit reports whether a snapshot was found
+	Hit bool
+
+	// Event is the published event
+	Event hi.Event
+
+	// Err and Duration are the result of loads, saves and publishes
+	Err      error
+	Duration time.Duration
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		nowFunc: time.Now,
+	}
+}
+
+// Recorder is an Observer keeping the calls made to it in memory, for tests
+type Recorder struct {
+	calls   []Call
+	nowFunc func() time.Time
+	lock    sync.Mutex
+}
+
+// Calls returns the recorded calls, in the order the observed work ended
+func (r *Recorder) Calls() []Call {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	calls := make([]Call, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+// CallsOf returns the recorded calls made to hook
+func (r *Recorder) CallsOf(hook Hook) []Call {
+	var calls []Call
+	for _, c := range r.Calls() {
+		if c.Hook == hook {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset removes the recorded calls
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = nil
+}
+
+func (r *Recorder) Load(ctx context.Context, aggregateType, aggregateID string) (context.Context, func(err error)) {
+	return ctx, r.start(Call{Hook: HookLoad, AggregateType: aggregateType, AggregateID: aggregateID})
+}
+
+func (r *Recorder) EventsRead(_ context.Context, aggregateType, aggregateID string, count int) {
+	r.record(Call{Hook: HookEventsRead, AggregateType: aggregateType, AggregateID: aggregateID, Count: count})
+}
+
+func (r *Recorder) Snapshot(_ context.Context, aggregateType, aggregateID string, hit bool) {
+	r.record(Call{Hook: HookSnapshot, AggregateType: aggregateType, AggregateID: aggregateID, Hit: hit})
+}
+
+func (r *Recorder) Save(ctx context.Context, aggregateType, aggregateID string, count int) (context.Context, func(err error)) {
+	return ctx, r.start(Call{Hook: HookSave, AggregateType: aggregateType, AggregateID: aggregateID, Count: count})
+}
+
+func (r *Recorder) Publish(ctx context.Context, event hi.Event) (context.Context, func(err error)) {
+	return ctx, r.start(Call{Hook: HookPublish, AggregateType: event.AggregateType, AggregateID: event.AggregateID, Event: event})
+}
+
+// start returns the function recording the call with its result
+func (r *Recorder) start(c Call) func(err error) {
+	started := r.nowFunc()
+	return func(err error) {
+		c.Err = err
+		c.Duration = r.nowFunc().Sub(started)
+		r.record(c)
+	}
+}
+
+func (r *Recorder) record(c Call) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = append(r.calls, c)
+}
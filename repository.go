@@ -3,12 +3,33 @@ package historia
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
 var (
 	ErrNoSnapShotInitialized = errors.New("no snapshot store has been initialized")
 	ErrSnapshotNotFound      = errors.New("snapshot not found")
 	ErrAggregateNotFound     = errors.New("aggregate not found")
+
+	// ErrStreamDeleted when reading or writing a stream that has been soft deleted
+	ErrStreamDeleted = errors.New("stream has been deleted")
+
+	// ErrTruncateBeyondVersion when truncating past the last event of a stream
+	ErrTruncateBeyondVersion = errors.New("can't truncate beyond the stream version")
+
+	// ErrDeleteNotSupported when the event store isn't a StreamDeleter
+	ErrDeleteNotSupported = errors.New("event store doesn't support deleting streams")
+
+	// ErrHistoryGap when the events of a stream don't follow the version of the aggregate,
+	// e.g. once the stream has been truncated past its last snapshot
+	ErrHistoryGap = errors.New("events missing from the aggregate history")
+
+	// ErrAtomicSaveNotSupported when committing changes to several aggregates to an event store that isn't an AtomicEventStore
+	ErrAtomicSaveNotSupported = errors.New("event store doesn't support saving several aggregates atomically")
+
+	// ErrOperationNotSupported when calling an optional operation through a repository that doesn't implement it
+	ErrOperationNotSupported = errors.New("repository doesn't support the operation")
 )
 
 type EventHandlerFunc func(ctx context.Context, event Event) error
@@ -17,6 +38,7 @@ type Repository interface {
 	// Get fetches the aggregates event and builds up the aggregate
 	// If there are snapshots for the aggregate, it will attempt to apply them,
 	// as well as all events after the version of the aggregate, if any.
+	// Events that can't be applied on the aggregate result in a *TransitionError.
 	Get(ctx context.Context, aggregateID string, aggregate Aggregate) error
 
 	// Save an aggregate's events
@@ -35,6 +57,61 @@ type Repository interface {
 	SubscriberSpecificEvent(f EventHandlerFunc, events ...EventData) *Subscription
 }
 
+// The operations below are optional, Repo implements all of them and repository middlewares should forward
+// the ones the repository they decorate implements. Callers find them with a type assertion on a Repository.
+
+// Reloader is implemented by repositories able to discard the unsaved changes of an aggregate
+type Reloader interface {
+	// Reload discards the unsaved changes of the aggregate and rebuilds it from the store
+	Reload(ctx context.Context, aggregate Aggregate) error
+}
+
+// AggregateInspector is implemented by repositories reading the state of an aggregate stream without building it
+type AggregateInspector interface {
+	// Exists reports whether the aggregate has been saved and not deleted, without building it
+	Exists(ctx context.Context, aggregateID string, aggregate Aggregate) (bool, error)
+
+	// CurrentVersion returns the version of the last stored event of the aggregate, without building it
+	CurrentVersion(ctx context.Context, aggregateID string, aggregate Aggregate) (Version, error)
+}
+
+// AggregateDeleter is implemented by repositories able to delete aggregates
+type AggregateDeleter interface {
+	// Delete soft deletes the aggregate
+	Delete(ctx context.Context, aggregateID string, aggregate Aggregate) error
+
+	// HardDelete removes all the events of the aggregate
+	HardDelete(ctx context.Context, aggregateID string, aggregate Aggregate) error
+
+	// Truncate removes the events of the aggregate with a version lower than beforeVersion
+	Truncate(ctx context.Context, aggregateID string, aggregate Aggregate, beforeVersion Version) error
+}
+
+// StreamAppender is implemented by repositories appending events to a stream without building its aggregate
+type StreamAppender interface {
+	// AppendToStream appends events to a stream without building its aggregate
+	AppendToStream(ctx context.Context, aggregateType, aggregateID string, expected ExpectedVersion, events []Event) error
+}
+
+// SnapshotSaver is implemented by repositories saving snapshots of aggregates
+type SnapshotSaver interface {
+	// SaveSnapshot saves the current state of the aggregate
+	SaveSnapshot(ctx context.Context, aggregate Aggregate) error
+}
+
+// UnitOfWorkFactory is implemented by repositories saving several aggregates as a whole.
+// Middlewares checking or changing saves should add a CommitHook to the units of work they return.
+type UnitOfWorkFactory interface {
+	// NewUnitOfWork returns an empty UnitOfWork committing to the repository
+	NewUnitOfWork() *UnitOfWork
+}
+
+// Binder is implemented by repositories generating the timestamps and IDs of the events of aggregates
+type Binder interface {
+	// Bind makes the aggregate use the repository clock and ID generators
+	Bind(aggregate Aggregate)
+}
+
 // EventStore interface exposes the methods an event store must uphold
 type EventStore interface {
 
@@ -48,12 +125,77 @@ type EventStore interface {
 	Close() error
 }
 
+// StreamInfo describes the stream of events of an aggregate
+type StreamInfo struct {
+	AggregateID   string
+	AggregateType string
+	Version       Version
+	EventCount    int
+	FirstEventAt  time.Time
+	LastEventAt   time.Time
+	Deleted       bool
+}
+
+// AtomicEventStore is an optional EventStore capability saving the events of several aggregates at once
+type AtomicEventStore interface {
+	// SaveEventStreams saves the events of every stream, each holding the events of one aggregate.
+	// Either all the streams are saved or none of them.
+	SaveEventStreams(ctx context.Context, streams [][]Event) error
+}
+
+// Tombstone is the data of the event appended to a stream when it is soft deleted.
+// It is registered in the DefaultRegistry, stores decoding event data with another EventRegistry
+// must register it there too for soft deleted streams to be read back.
+type Tombstone struct{}
+
+// StreamDeleter is an optional EventStore capability removing streams or parts of them.
+// The semantics stores must follow are described by eventstore.AcceptanceTest.
+type StreamDeleter interface {
+	// SoftDeleteStream appends a Tombstone event to the stream, after which reading or
+	// writing the stream fails with ErrStreamDeleted.
+	SoftDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error
+
+	// HardDeleteStream removes all the events of the stream, as if it never existed.
+	HardDeleteStream(ctx context.Context, aggregateID string, aggregateType string) error
+
+	// TruncateStream removes the events with a version lower than beforeVersion,
+	// the last event is always kept so the stream version is preserved.
+	TruncateStream(ctx context.Context, aggregateID string, aggregateType string, beforeVersion Version) error
+}
+
+// StreamInfoReader is an optional EventStore capability describing a stream without reading its events
+type StreamInfoReader interface {
+	// StreamInfo returns ErrNoEvents if there is no stream for the aggregate
+	StreamInfo(ctx context.Context, aggregateID string, aggregateType string) (*StreamInfo, error)
+}
+
 // Aggregate interface to use the aggregate root specific methods
 type Aggregate interface {
 	Root() *AggregateBase
 	Transition(evt Event)
 }
 
+// TransitionerE can be implemented by aggregates whose transitions can fail,
+// TransitionE is then called instead of Transition.
+type TransitionerE interface {
+	TransitionE(evt Event) error
+}
+
+// Validator can be implemented by aggregates declaring their invariants in a single method.
+// Validate is called after every tracked change and before the aggregate is saved.
+type Validator interface {
+	Validate() error
+}
+
+// Invariant is a rule the state of an aggregate must satisfy
+type Invariant func() error
+
+// InvariantHolder can be implemented by aggregates declaring their invariants as a list of rules,
+// they are checked at the same points as Validator.
+type InvariantHolder interface {
+	Invariants() []Invariant
+}
+
 type SnapShooter interface {
 	// ApplySnapshot retrieves and applies snapshots onto the given Aggregate.
 	ApplySnapshot(ctx context.Context, aggregateID string, aggregate Aggregate) error
@@ -64,13 +206,71 @@ type SnapShooter interface {
 	SaveSnapshot(ctx context.Context, aggregate Aggregate) error
 }
 
+type RepositoryOption func(r *Repo)
+
+// WithRepositoryNowFunc sets the clock handed to the aggregates of the repository
+func WithRepositoryNowFunc(f func() time.Time) RepositoryOption {
+	return func(r *Repo) {
+		r.nowFunc = f
+	}
+}
+
+// WithRepositoryIDFunc sets the ID generator handed to the aggregates of the repository
+func WithRepositoryIDFunc(f func() string) RepositoryOption {
+	return func(r *Repo) {
+		r.idFunc = f
+	}
+}
+
+// WithRepositoryEventIDFunc sets the event ID generator handed to the aggregates of the repository
+func WithRepositoryEventIDFunc(f EventIDFunc) RepositoryOption {
+	return func(r *Repo) {
+		r.eventIDFunc = f
+	}
+}
+
+// WithRepositoryObserver sets the observer notified when loading and saving aggregates,
+// as well as when publishing their events to subscribers
+func WithRepositoryObserver(o Observer) RepositoryOption {
+	return func(r *Repo) {
+		r.observer = o
+		r.EventStream.observer = o
+	}
+}
+
+// WithRepositoryLogger sets the logger of the repository and of its event stream
+func WithRepositoryLogger(l Logger) RepositoryOption {
+	return func(r *Repo) {
+		r.logger = l
+		r.EventStream.logger = l
+	}
+}
+
+// WithRepositoryEnrichers sets the enrichers of the metadata of the saved events,
+// replacing the default ContextMetadata one. Values already in the metadata of the events are kept,
+// later enrichers override the values of earlier ones.
+func WithRepositoryEnrichers(enrichers ...MetadataEnricher) RepositoryOption {
+	return func(r *Repo) {
+		r.enrichers = enrichers
+	}
+}
+
 // NewRepository creates and returns a new instance of Repo
-func NewRepository(es EventStore, s SnapShooter) *Repo {
-	return &Repo{
+func NewRepository(es EventStore, s SnapShooter, opts ...RepositoryOption) *Repo {
+	r := &Repo{
 		EventStream: NewEventStream(),
 		eventStore:  es,
 		snapper:     s,
+		observer:    NopObserver{},
+		logger:      NopLogger{},
+		enrichers:   []MetadataEnricher{ContextMetadata},
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r
 }
 
 // Repo is the returned instance from the factory function
@@ -78,24 +278,62 @@ type Repo struct {
 	*EventStream
 	eventStore EventStore
 	snapper    SnapShooter
+
+	nowFunc     func() time.Time
+	idFunc      func() string
+	eventIDFunc EventIDFunc
+
+	observer  Observer
+	logger    Logger
+	enrichers []MetadataEnricher
+}
+
+// Bind hands the repository clock and ID generators to the aggregate,
+// unless the aggregate has its own. Aggregates passed to Get and Save are bound automatically,
+// new aggregates should be bound before tracking changes to generate their IDs with the repository generator.
+func (r *Repo) Bind(aggregate Aggregate) {
+	root := aggregate.Root()
+	if root.nowFunc == nil {
+		root.nowFunc = r.nowFunc
+	}
+	if root.idFunc == nil {
+		root.idFunc = r.idFunc
+	}
+	if root.eventIDFunc == nil {
+		root.eventIDFunc = r.eventIDFunc
+	}
 }
 
 // Get fetches the aggregates event and builds up the aggregate
 // If there is a snapshot store, try to fetch a snapshot of the aggregate and
 // event after the version of the aggregate, if any.
-func (r *Repo) Get(ctx context.Context, aggregateID string, aggregate Aggregate) error {
+// ErrHistoryGap is returned when events are missing between the snapshot, if any, and the stored events.
+func (r *Repo) Get(ctx context.Context, aggregateID string, aggregate Aggregate) (err error) {
+	r.Bind(aggregate)
+
+	aggregateType := formatAggregatePathType(aggregate)
+	ctx, end := r.observer.Load(ctx, aggregateType, aggregateID)
+	defer func() { end(err) }()
+
 	// if there is a snapshot store try fetch aggregate snapshot
 	if r.snapper != nil {
 		err := r.snapper.ApplySnapshot(ctx, aggregateID, aggregate)
 		if err != nil && !errors.Is(err, ErrSnapshotNotFound) {
+			r.logger.Error("applying snapshot failed",
+				LogKeyAggregateType, aggregateType,
+				LogKeyAggregateID, aggregateID,
+				LogKeyError, err,
+			)
 			return err
 		}
 	}
 
 	// fetch events after the current version of the aggregate that could be fetched from the snapshot store
 	root := aggregate.Root()
-	aggregateType := formatAggregatePathType(aggregate)
 	events, err := r.eventStore.GetEvents(ctx, aggregateID, aggregateType, root.Version())
+	if errors.Is(err, ErrStreamDeleted) {
+		return ErrAggregateNotFound
+	}
 	if err != nil {
 		if !errors.Is(err, ErrNoEvents) {
 			return err
@@ -104,34 +342,197 @@ func (r *Repo) Get(ctx context.Context, aggregateID string, aggregate Aggregate)
 			return ErrAggregateNotFound
 		}
 	}
+	r.observer.EventsRead(ctx, aggregateType, aggregateID, len(events))
+
+	if len(events) > 0 && events[0].Version != root.Version()+1 {
+		err := fmt.Errorf("%w: expected version %d, first stored event at version %d", ErrHistoryGap, root.Version()+1, events[0].Version)
+		r.logger.Error("building aggregate from history failed",
+			LogKeyAggregateType, aggregateType,
+			LogKeyAggregateID, aggregateID,
+			LogKeyVersion, root.Version(),
+			LogKeyError, err,
+		)
+		return err
+	}
 
 	// apply the event on the aggregate
-	root.BuildFromHistory(aggregate, events)
+	if err := root.BuildFromHistory(aggregate, events); err != nil {
+		r.logger.Error("building aggregate from history failed",
+			LogKeyAggregateType, aggregateType,
+			LogKeyAggregateID, aggregateID,
+			LogKeyVersion, root.Version(),
+			LogKeyError, err,
+		)
+		return err
+	}
+
+	r.logger.Debug("aggregate loaded",
+		LogKeyAggregateType, aggregateType,
+		LogKeyAggregateID, aggregateID,
+		LogKeyVersion, root.Version(),
+		LogKeyEvents, len(events),
+	)
 	return nil
 }
 
-// Save an aggregates events
+// Save an aggregates events.
+// If the aggregate invariants don't hold an *InvariantError is returned and the aggregate is rolled back to its last saved state.
+// The metadata returned by the repository enrichers for ctx is merged into the metadata of the events.
 func (r *Repo) Save(ctx context.Context, aggregate Aggregate) error {
+	r.Bind(aggregate)
+
 	root := aggregate.Root()
-	if err := r.eventStore.SaveEvents(ctx, root.events); err != nil {
+	aggregateType := formatAggregatePathType(aggregate)
+	if err := root.checkInvariants(aggregate); err != nil {
+		r.logger.Warn("aggregate invariants violated, unsaved changes rolled back",
+			LogKeyAggregateType, aggregateType,
+			LogKeyAggregateID, root.ID(),
+			LogKeyError, err,
+		)
+		root.rollback(aggregate)
+		return err
+	}
+
+	enrich(ctx, r.enrichers, root.events)
+
+	saveCtx, end := r.observer.Save(ctx, aggregateType, root.ID(), len(root.events))
+	err := r.eventStore.SaveEvents(saveCtx, root.events)
+	end(err)
+	if err != nil {
+		r.logger.Warn("saving aggregate failed",
+			LogKeyAggregateType, aggregateType,
+			LogKeyAggregateID, root.ID(),
+			LogKeyVersion, root.Version(),
+			LogKeyError, err,
+		)
 		return err
 	}
 
 	// publish the saved events to subscribers
 	if err := r.Update(ctx, aggregate, root.Events()); err != nil {
+		r.logger.Error("publishing saved events failed",
+			LogKeyAggregateType, aggregateType,
+			LogKeyAggregateID, root.ID(),
+			LogKeyVersion, root.Version(),
+			LogKeyError, err,
+		)
 		return err
 	}
 
+	r.logger.Debug("aggregate saved",
+		LogKeyAggregateType, aggregateType,
+		LogKeyAggregateID, root.ID(),
+		LogKeyVersion, root.Version(),
+		LogKeyEvents, len(root.events),
+	)
+
 	// update the internal aggregate state
 	root.update()
 	return nil
 }
 
+// Delete soft deletes the aggregate, after which Get returns ErrAggregateNotFound and saving it fails with ErrStreamDeleted
+func (r *Repo) Delete(ctx context.Context, aggregateID string, aggregate Aggregate) error {
+	deleter, ok := capability[StreamDeleter](r.eventStore)
+	if !ok {
+		return ErrDeleteNotSupported
+	}
+	return deleter.SoftDeleteStream(ctx, aggregateID, formatAggregatePathType(aggregate))
+}
+
+// HardDelete removes all the events of the aggregate
+func (r *Repo) HardDelete(ctx context.Context, aggregateID string, aggregate Aggregate) error {
+	deleter, ok := capability[StreamDeleter](r.eventStore)
+	if !ok {
+		return ErrDeleteNotSupported
+	}
+	return deleter.HardDeleteStream(ctx, aggregateID, formatAggregatePathType(aggregate))
+}
+
+// Truncate removes the events of the aggregate with a version lower than beforeVersion.
+// It's meant to reclaim space after a snapshot has been saved, aggregates without a snapshot
+// at or after beforeVersion can't be rebuilt anymore, Get returns ErrHistoryGap for them.
+func (r *Repo) Truncate(ctx context.Context, aggregateID string, aggregate Aggregate, beforeVersion Version) error {
+	deleter, ok := capability[StreamDeleter](r.eventStore)
+	if !ok {
+		return ErrDeleteNotSupported
+	}
+	return deleter.TruncateStream(ctx, aggregateID, formatAggregatePathType(aggregate), beforeVersion)
+}
+
+// Exists reports whether the aggregate has been saved and not deleted, without building it
+func (r *Repo) Exists(ctx context.Context, aggregateID string, aggregate Aggregate) (bool, error) {
+	info, err := r.streamInfo(ctx, aggregateID, formatAggregatePathType(aggregate))
+	if errors.Is(err, ErrNoEvents) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.Deleted, nil
+}
+
+// CurrentVersion returns the version of the last stored event of the aggregate, without building it.
+// Returns ErrAggregateNotFound if the aggregate doesn't exist.
+func (r *Repo) CurrentVersion(ctx context.Context, aggregateID string, aggregate Aggregate) (Version, error) {
+	info, err := r.streamInfo(ctx, aggregateID, formatAggregatePathType(aggregate))
+	if errors.Is(err, ErrNoEvents) {
+		return 0, ErrAggregateNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if info.Deleted {
+		return 0, ErrAggregateNotFound
+	}
+	return info.Version, nil
+}
+
+// streamInfo describes the stream with the event store, reading all its events if it isn't a StreamInfoReader
+func (r *Repo) streamInfo(ctx context.Context, aggregateID string, aggregateType string) (*StreamInfo, error) {
+	if reader, ok := capability[StreamInfoReader](r.eventStore); ok {
+		return reader.StreamInfo(ctx, aggregateID, aggregateType)
+	}
+
+	events, err := r.eventStore.GetEvents(ctx, aggregateID, aggregateType, 0)
+	if errors.Is(err, ErrStreamDeleted) {
+		return &StreamInfo{AggregateID: aggregateID, AggregateType: aggregateType, Deleted: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := events[0], events[len(events)-1]
+	return &StreamInfo{
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		Version:       last.Version,
+		EventCount:    len(events),
+		FirstEventAt:  first.Timestamp,
+		LastEventAt:   last.Timestamp,
+	}, nil
+}
+
+// Reload discards the unsaved changes of the aggregate and rebuilds it from the persisted snapshot and events.
+// The aggregate is reset to its zero value first, state set outside of events, e.g. by a constructor, is lost.
+func (r *Repo) Reload(ctx context.Context, aggregate Aggregate) error {
+	root := aggregate.Root()
+	id := root.ID()
+	if id == emptyAggregateID {
+		return ErrAggregateMissingID
+	}
+
+	root.reset(aggregate)
+	return r.Get(ctx, id, aggregate)
+}
+
 // SaveSnapshot saves the current state of the aggregate but only if it has no unsaved events
 func (r *Repo) SaveSnapshot(ctx context.Context, aggregate Aggregate) error {
 	if r.snapper == nil {
 		return ErrNoSnapShotInitialized
 	}
 
+	r.Bind(aggregate)
+
 	return r.snapper.SaveSnapshot(ctx, aggregate)
 }
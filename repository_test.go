@@ -3,7 +3,10 @@ package historia
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,6 +20,48 @@ func Test_NewRepository_should_return_valid_instance(t *testing.T) {
 	assert.Equal(t, snapMock, repo.snapper)
 }
 
+func Test_NewRepository_should_apply_options(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	id := func() string { return "id" }
+	repo := NewRepository(nil, nil, WithRepositoryNowFunc(now), WithRepositoryIDFunc(id))
+
+	assert.Equal(t, reflect.ValueOf(now).Pointer(), reflect.ValueOf(repo.nowFunc).Pointer())
+	assert.Equal(t, reflect.ValueOf(id).Pointer(), reflect.ValueOf(repo.idFunc).Pointer())
+}
+
+func Test_Repo_Bind_should_hand_clock_and_id_generator_to_aggregate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo := NewRepository(nil, nil,
+		WithRepositoryNowFunc(func() time.Time { return now }),
+		WithRepositoryIDFunc(func() string { return "from-repo" }),
+	)
+
+	p1 := repoAggregate{}
+	repo.Bind(&p1)
+	p1.TrackChange(&p1, &repoEvent1{})
+
+	assert.Equal(t, "from-repo", p1.ID())
+	assert.Equal(t, "from-repo", p1.events[0].ID)
+	assert.Equal(t, now, p1.events[0].Timestamp)
+
+	p3 := repoAggregate{}
+	NewRepository(nil, nil, WithRepositoryEventIDFunc(DeterministicEventID)).Bind(&p3)
+	_ = p3.SetID("known")
+	p3.TrackChange(&p3, &repoEvent1{})
+	assert.Equal(t, DeterministicEventID(formatAggregatePathType(&p3), "known", 1, &repoEvent1{}), p3.events[0].ID)
+
+	// the aggregates own generator takes precedence
+	p2 := repoAggregate{}
+	p2.SetIDFunc(func() string { return "from-aggregate" })
+	repo.Bind(&p2)
+	p2.TrackChange(&p2, &repoEvent1{})
+
+	assert.Equal(t, "from-aggregate", p2.ID())
+	assert.Equal(t, now, p2.events[0].Timestamp)
+}
+
 func Test_Repo_Save_should_return_eventStore_error(t *testing.T) {
 	e := errors.New("it went boom")
 	esMock := &eventStoreMocker{
@@ -53,6 +98,27 @@ func Test_Repo_Save_should_call_eventStore_Update(t *testing.T) {
 	assert.Len(t, p1.Events(), 0)
 }
 
+func Test_Repo_Save_should_reject_aggregate_violating_invariants(t *testing.T) {
+	esMock := &eventStoreMocker{
+		save: func(context.Context, []Event) error {
+			t.Fatal("events should not be saved")
+			return nil
+		},
+	}
+	repo := NewRepository(esMock, nil)
+
+	p := invAgg{maxAge: 1}
+	_ = p.SetID("a")
+	assert.NoError(t, p.TrackChange(&p, &born{Name: "Someone"}))
+
+	// invariants can also be broken by changes outside of tracked events
+	p.maxAge = -1
+	assert.ErrorIs(t, repo.Save(context.Background(), &p), ErrInvariantViolation)
+	assert.False(t, p.HasUnsavedEvents())
+	assert.Equal(t, Version(0), p.Version())
+	assert.Equal(t, "", p.name)
+}
+
 func Test_Repo_Get_should_return_error_when_snapper_fails(t *testing.T) {
 	err := errors.New("well no")
 	sn := &snapMocker{
@@ -62,6 +128,19 @@ func Test_Repo_Get_should_return_error_when_snapper_fails(t *testing.T) {
 	assert.ErrorIs(t, repo.Get(context.Background(), "asd", &repoAggregate{}), err)
 }
 
+func Test_Repo_Get_should_return_error_when_history_has_a_gap(t *testing.T) {
+	es := &eventStoreMocker{
+		get: func(_ context.Context, id string, _ string, afterVersion Version) ([]Event, error) {
+			assert.Equal(t, Version(0), afterVersion)
+			return []Event{{AggregateID: id, Version: 5, Data: &repoEvent1{}}}, nil
+		},
+	}
+
+	err := NewRepository(es, nil).Get(context.Background(), "asd", &repoAggregate{})
+	assert.ErrorIs(t, err, ErrHistoryGap)
+	assert.EqualError(t, err, "events missing from the aggregate history: expected version 1, first stored event at version 5")
+}
+
 func Test_Repo_Get_should_return_error_when_eventStore_fails(t *testing.T) {
 	err := errors.New("well no")
 	es := &eventStoreMocker{
@@ -113,6 +192,163 @@ func Test_Repo_Get_should_build_aggregate_from_history(t *testing.T) {
 	assert.Equal(t, "Happy", ag.name)
 }
 
+func Test_Repo_Get_should_return_TransitionError(t *testing.T) {
+	es := &eventStoreMocker{
+		get: func(context.Context, string, string, Version) ([]Event, error) {
+			return []Event{
+				{AggregateID: "asd", Version: 1, Data: &born{}},
+				{AggregateID: "asd", Version: 2, Data: &born{}},
+			}, nil
+		},
+	}
+
+	repo := NewRepository(es, nil)
+	err := repo.Get(context.Background(), "asd", &aggAggE{})
+
+	var terr *TransitionError
+	assert.ErrorAs(t, err, &terr)
+	assert.ErrorIs(t, err, errAlreadyBorn)
+	assert.Equal(t, Version(2), terr.Version)
+}
+
+func Test_Repo_Get_should_return_not_found_when_stream_deleted(t *testing.T) {
+	es := &eventStoreMocker{
+		get: func(context.Context, string, string, Version) ([]Event, error) { return nil, ErrStreamDeleted },
+	}
+	repo := NewRepository(es, nil)
+	assert.ErrorIs(t, repo.Get(context.Background(), "asd", &repoAggregate{}), ErrAggregateNotFound)
+}
+
+func Test_Repo_Delete_HardDelete_Truncate(t *testing.T) {
+	t.Run("should return error when store doesn't support deleting", func(t *testing.T) {
+		repo := NewRepository(&eventStoreMocker{}, nil)
+		assert.ErrorIs(t, repo.Delete(context.Background(), "asd", &repoAggregate{}), ErrDeleteNotSupported)
+		assert.ErrorIs(t, repo.HardDelete(context.Background(), "asd", &repoAggregate{}), ErrDeleteNotSupported)
+		assert.ErrorIs(t, repo.Truncate(context.Background(), "asd", &repoAggregate{}, 1), ErrDeleteNotSupported)
+	})
+
+	t.Run("should call store", func(t *testing.T) {
+		es := &streamDeleterMocker{}
+		repo := NewRepository(es, nil)
+		aggregateType := formatAggregatePathType(&repoAggregate{})
+
+		assert.NoError(t, repo.Delete(context.Background(), "asd", &repoAggregate{}))
+		assert.NoError(t, repo.HardDelete(context.Background(), "asd", &repoAggregate{}))
+		assert.NoError(t, repo.Truncate(context.Background(), "asd", &repoAggregate{}, 3))
+
+		assert.Equal(t, []string{
+			"soft asd " + aggregateType,
+			"hard asd " + aggregateType,
+			"truncate asd " + aggregateType + " 3",
+		}, es.calls)
+	})
+}
+
+func Test_Repo_Exists_and_CurrentVersion(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		title   string
+		store   EventStore
+		exists  bool
+		version Version
+		err     error
+	}{
+		{
+			title: "should read events without StreamInfoReader",
+			store: &eventStoreMocker{
+				get: func(context.Context, string, string, Version) ([]Event, error) {
+					return []Event{{Version: 1}, {Version: 2}}, nil
+				},
+			},
+			exists:  true,
+			version: 2,
+		},
+		{
+			title: "should not exist without events",
+			store: &eventStoreMocker{
+				get: func(context.Context, string, string, Version) ([]Event, error) { return nil, ErrNoEvents },
+			},
+			err: ErrAggregateNotFound,
+		},
+		{
+			title: "should not exist when deleted without StreamInfoReader",
+			store: &eventStoreMocker{
+				get: func(context.Context, string, string, Version) ([]Event, error) { return nil, ErrStreamDeleted },
+			},
+			err: ErrAggregateNotFound,
+		},
+		{
+			title:   "should use StreamInfoReader",
+			store:   &streamInfoMocker{info: &StreamInfo{Version: 7}},
+			exists:  true,
+			version: 7,
+		},
+		{
+			title: "should not exist when deleted",
+			store: &streamInfoMocker{info: &StreamInfo{Version: 7, Deleted: true}},
+			err:   ErrAggregateNotFound,
+		},
+		{
+			title: "should return store error",
+			store: &streamInfoMocker{err: errBoom},
+			err:   errBoom,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.title, func(t *testing.T) {
+			repo := NewRepository(test.store, nil)
+
+			exists, err := repo.Exists(context.Background(), "asd", &repoAggregate{})
+			assert.Equal(t, test.exists, exists)
+			if test.err == errBoom {
+				assert.ErrorIs(t, err, errBoom)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			version, err := repo.CurrentVersion(context.Background(), "asd", &repoAggregate{})
+			assert.Equal(t, test.version, version)
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func Test_Repo_Reload_should_return_error_when_aggregate_has_no_id(t *testing.T) {
+	repo := NewRepository(nil, nil)
+	assert.ErrorIs(t, repo.Reload(context.Background(), &repoAggregate{}), ErrAggregateMissingID)
+}
+
+func Test_Repo_Reload_should_rebuild_aggregate_from_store(t *testing.T) {
+	events := []Event{
+		{AggregateID: "asd", Version: 1, Data: &repoEvent1{Name: "Persisted"}},
+	}
+
+	es := &eventStoreMocker{
+		get: func(_ context.Context, _ string, _ string, after Version) ([]Event, error) {
+			assert.Equal(t, Version(0), after)
+			return events, nil
+		},
+	}
+
+	now := func() time.Time { return time.Time{} }
+	repo := NewRepository(es, nil)
+	ag := &repoAggregate{}
+	ag.SetNowFunc(now)
+	assert.NoError(t, repo.Get(context.Background(), "asd", ag))
+
+	_ = ag.TrackChange(ag, &repoEvent1{Name: "Unsaved"})
+	ag.name = "Mutated"
+
+	assert.NoError(t, repo.Reload(context.Background(), ag))
+	assert.Equal(t, "Persisted", ag.name)
+	assert.Equal(t, "asd", ag.ID())
+	assert.Equal(t, Version(1), ag.Version())
+	assert.False(t, ag.HasUnsavedEvents())
+	assert.Equal(t, reflect.ValueOf(now).Pointer(), reflect.ValueOf(ag.nowFunc).Pointer())
+}
+
 func Test_Repo_SaveSnapshot_should_return_error_if_no_snapper(t *testing.T) {
 	r := NewRepository(nil, nil)
 	assert.ErrorIs(t, r.SaveSnapshot(context.Background(), &repoAggregate{}), ErrNoSnapShotInitialized)
@@ -165,6 +401,36 @@ func (e *eventStoreMocker) Close() error {
 	return e.close()
 }
 
+type streamInfoMocker struct {
+	eventStoreMocker
+	info *StreamInfo
+	err  error
+}
+
+func (s *streamInfoMocker) StreamInfo(context.Context, string, string) (*StreamInfo, error) {
+	return s.info, s.err
+}
+
+type streamDeleterMocker struct {
+	eventStoreMocker
+	calls []string
+}
+
+func (s *streamDeleterMocker) SoftDeleteStream(_ context.Context, aggregateID string, aggregateType string) error {
+	s.calls = append(s.calls, "soft "+aggregateID+" "+aggregateType)
+	return nil
+}
+
+func (s *streamDeleterMocker) HardDeleteStream(_ context.Context, aggregateID string, aggregateType string) error {
+	s.calls = append(s.calls, "hard "+aggregateID+" "+aggregateType)
+	return nil
+}
+
+func (s *streamDeleterMocker) TruncateStream(_ context.Context, aggregateID string, aggregateType string, beforeVersion Version) error {
+	s.calls = append(s.calls, fmt.Sprintf("truncate %s %s %d", aggregateID, aggregateType, beforeVersion))
+	return nil
+}
+
 type snapMocker struct {
 	apply func(ctx context.Context, aggregateID string, aggregate Aggregate) error
 	save  func(ctx context.Context, aggregate Aggregate) error
@@ -0,0 +1,116 @@
+package historia
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrUnhandledEvent when an event is routed to an aggregate that has no handler for it
+	ErrUnhandledEvent = errors.New("no handler registered for event")
+
+	// ErrRegistryNotListable when validating routes against a registry that can't list the names it registered
+	ErrRegistryNotListable = errors.New("event registry doesn't list its event data names")
+)
+
+// EventRouted is implemented by aggregates registering typed handlers per event instead of
+// switching over the event data in Transition. Events applied to such aggregates are dispatched
+// through the router, Transition is not called and can be left empty.
+type EventRouted interface {
+	Routes(r *EventRouter)
+}
+
+// NewEventRouter creates and returns an empty EventRouter
+func NewEventRouter() *EventRouter {
+	return &EventRouter{
+		handlers: make(map[reflect.Type]func(evt Event)),
+	}
+}
+
+// EventRouter dispatches events to the handler registered for the type of their data
+type EventRouter struct {
+	handlers map[reflect.Type]func(evt Event)
+}
+
+// Handle registers fn to be called with the data of events holding a T
+func Handle[T EventData](r *EventRouter, fn func(data T)) {
+	HandleEvent(r, func(_ Event, data T) { fn(data) })
+}
+
+// HandleEvent registers fn to be called with events holding a T, for handlers that need the event itself
+func HandleEvent[T EventData](r *EventRouter, fn func(evt Event, data T)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	r.handlers[t] = func(evt Event) {
+		fn(evt, evt.Data.(T))
+	}
+}
+
+// Route calls the handler registered for the event data, returns ErrUnhandledEvent if there is none
+func (r *EventRouter) Route(evt Event) error {
+	h, ok := r.handlers[reflect.TypeOf(evt.Data)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnhandledEvent, evt.Reason())
+	}
+
+	h(evt)
+	return nil
+}
+
+// Handles reports whether a handler is registered for the type of data
+func (r *EventRouter) Handles(data EventData) bool {
+	_, ok := r.handlers[reflect.TypeOf(data)]
+	return ok
+}
+
+// ValidateRoutes makes sure every event data registered in the registry is handled by at least one of the aggregates,
+// it's meant to be called at startup. Aggregates that aren't EventRouted are ignored, and so is the Tombstone.
+// The registry must list the names it registered with a Names() []string method, as EventRegister does,
+// otherwise ErrRegistryNotListable is returned.
+func ValidateRoutes(registry EventRegistry, aggregates ...Aggregate) error {
+	listable, ok := registry.(interface{ Names() []string })
+	if !ok {
+		return ErrRegistryNotListable
+	}
+
+	var routers []*EventRouter
+	for _, aggregate := range aggregates {
+		if routed, ok := aggregate.(EventRouted); ok {
+			r := NewEventRouter()
+			routed.Routes(r)
+			routers = append(routers, r)
+		}
+	}
+
+	var missing []string
+	for _, name := range listable.Names() {
+		data, err := registry.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, ok := data.(*Tombstone); ok {
+			// tombstones are handled by the stores, not by aggregates
+			continue
+		}
+
+		if !anyHandles(routers, data) {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnhandledEvent, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func anyHandles(routers []*EventRouter, data EventData) bool {
+	for _, r := range routers {
+		if r.Handles(data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package historia
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EventRouter_Route_should_call_typed_handler(t *testing.T) {
+	r := NewEventRouter()
+
+	var created *routeCreated
+	var renamed Event
+	Handle(r, func(data *routeCreated) { created = data })
+	HandleEvent(r, func(evt Event, _ *routeRenamed) { renamed = evt })
+
+	assert.NoError(t, r.Route(Event{Data: &routeCreated{Name: "first"}}))
+	assert.Equal(t, "first", created.Name)
+
+	assert.NoError(t, r.Route(Event{Version: 2, Data: &routeRenamed{Name: "second"}}))
+	assert.Equal(t, Version(2), renamed.Version)
+}
+
+func Test_EventRouter_Route_should_return_error_for_unhandled_event(t *testing.T) {
+	r := NewEventRouter()
+	Handle(r, func(data *routeCreated) {})
+
+	err := r.Route(Event{Data: &routeRenamed{}})
+	assert.ErrorIs(t, err, ErrUnhandledEvent)
+	assert.Contains(t, err.Error(), "routeRenamed")
+}
+
+func Test_EventRouter_Handles(t *testing.T) {
+	r := NewEventRouter()
+	Handle(r, func(data *routeCreated) {})
+
+	assert.True(t, r.Handles(&routeCreated{}))
+	assert.False(t, r.Handles(&routeRenamed{}))
+	assert.False(t, r.Handles(routeCreated{}))
+}
+
+func Test_AggregateBase_should_route_events_of_EventRouted_aggregates(t *testing.T) {
+	p := routeAgg{}
+	p.TrackChange(&p, &routeCreated{Name: "first"})
+	p.TrackChange(&p, &routeRenamed{Name: "second"})
+	assert.Equal(t, "second", p.name)
+	assert.Equal(t, 1, p.renames)
+
+	p1 := routeAgg{}
+	assert.NoError(t, p1.BuildFromHistory(&p1, p.Events()))
+	assert.Equal(t, "second", p1.name)
+	assert.Equal(t, Version(2), p1.Version())
+}
+
+func Test_AggregateBase_BuildFromHistory_should_return_error_for_unhandled_event(t *testing.T) {
+	events := []Event{
+		{AggregateID: "a", Version: 1, Data: &routeCreated{Name: "first"}},
+		{AggregateID: "a", Version: 2, Data: &routeForgotten{}},
+	}
+
+	p := routeAgg{}
+	assert.ErrorIs(t, p.BuildFromHistory(&p, events), ErrUnhandledEvent)
+	assert.Equal(t, Version(1), p.Version())
+}
+
+func Test_AggregateBase_TrackChange_should_return_error_for_unhandled_event(t *testing.T) {
+	p := routeAgg{}
+	assert.ErrorIs(t, p.TrackChange(&p, &routeForgotten{}), ErrUnhandledEvent)
+	assert.False(t, p.HasUnsavedEvents())
+}
+
+func Test_Repo_Get_should_return_error_for_unhandled_event(t *testing.T) {
+	es := &eventStoreMocker{
+		get: func(context.Context, string, string, Version) ([]Event, error) {
+			return []Event{{Version: 1, Data: &routeForgotten{}}}, nil
+		},
+	}
+
+	repo := NewRepository(es, nil)
+	assert.ErrorIs(t, repo.Get(context.Background(), "a", &routeAgg{}), ErrUnhandledEvent)
+}
+
+func Test_ValidateRoutes(t *testing.T) {
+	var registry EventRegistry = NewEventRegistry()
+	_ = registry.Register(func() EventData { return &routeCreated{} })
+	_ = registry.Register(func() EventData { return &routeRenamed{} })
+
+	assert.NoError(t, ValidateRoutes(registry, &routeAgg{}, &aggAgg{}))
+	_ = registry.Register(func() EventData { return &Tombstone{} })
+	assert.NoError(t, ValidateRoutes(registry, &routeAgg{}), "tombstones are not handled by aggregates")
+
+	_ = registry.Register(func() EventData { return &routeForgotten{} })
+	err := ValidateRoutes(registry, &routeAgg{})
+	assert.ErrorIs(t, err, ErrUnhandledEvent)
+	assert.Contains(t, err.Error(), registry.GetName(&routeForgotten{}))
+}
+
+func Test_ValidateRoutes_should_fail_when_the_registry_does_not_list_names(t *testing.T) {
+	err := ValidateRoutes(&unlistedRegistry{EventRegistry: NewEventRegistry()}, &routeAgg{})
+	assert.ErrorIs(t, err, ErrRegistryNotListable)
+}
+
+// region mocks
+
+type routeCreated struct{ Name string }
+type routeRenamed struct{ Name string }
+type routeForgotten struct{}
+
+// unlistedRegistry implements EventRegistry only, without Names
+type unlistedRegistry struct {
+	EventRegistry
+}
+
+type routeAgg struct {
+	AggregateBase
+
+	name    string
+	renames int
+}
+
+func (p *routeAgg) Routes(r *EventRouter) {
+	Handle(r, p.created)
+	Handle(r, p.renamed)
+}
+
+func (p *routeAgg) Transition(Event) {}
+
+func (p *routeAgg) created(data *routeCreated) {
+	p.name = data.Name
+}
+
+func (p *routeAgg) renamed(data *routeRenamed) {
+	p.name = data.Name
+	p.renames++
+}
+
+// endregion
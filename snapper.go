@@ -37,18 +37,53 @@ type Marshaller interface {
 	Unmarshal(data []byte, v interface{}) error
 }
 
+type SnapperOption func(s *Snapper)
+
+// WithSnapperNowFunc sets the clock used to timestamp snapshots,
+// by default the clock of the aggregate is used.
+func WithSnapperNowFunc(f func() time.Time) SnapperOption {
+	return func(s *Snapper) {
+		s.nowFunc = f
+	}
+}
+
+// WithSnapperLogger sets the logger of the snapper
+func WithSnapperLogger(l Logger) SnapperOption {
+	return func(s *Snapper) {
+		s.logger = l
+	}
+}
+
+// WithSnapperObserver sets the observer notified of snapshot hits and misses
+func WithSnapperObserver(o Observer) SnapperOption {
+	return func(s *Snapper) {
+		s.observer = o
+	}
+}
+
 // NewSnapper creates and returns an instance of Snapper
-func NewSnapper(ss SnapshotStore, m Marshaller) *Snapper {
-	return &Snapper{
+func NewSnapper(ss SnapshotStore, m Marshaller, opts ...SnapperOption) *Snapper {
+	s := &Snapper{
 		store:      ss,
 		marshaller: m,
+		observer:   NopObserver{},
+		logger:     NopLogger{},
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // Snapper saves/applies snapshots to/from Aggregate
 type Snapper struct {
 	store      SnapshotStore
 	marshaller Marshaller
+	nowFunc    func() time.Time
+	observer   Observer
+	logger     Logger
 }
 
 func (s *Snapper) ApplySnapshot(ctx context.Context, aggregateID string, aggregate Aggregate) error {
@@ -59,21 +94,34 @@ func (s *Snapper) ApplySnapshot(ctx context.Context, aggregateID string, aggrega
 
 	t := formatAggregatePathType(aggregate)
 	snap, err := s.store.Get(ctx, aggregateID, t)
+	if err != nil && !errors.Is(err, ErrSnapshotNotFound) {
+		return err
+	}
+
+	s.observer.Snapshot(ctx, t, aggregateID, err == nil)
 	if err != nil {
 		return err
 	}
 
 	var state SnapshotBody
 	if err := s.marshaller.Unmarshal(snap.State, &state); err != nil {
+		s.logSnapshotError("unmarshalling snapshot failed", snap, err)
 		return err
 	}
 
 	if err := st.ApplySnapshot(&state); err != nil {
+		s.logSnapshotError("applying snapshot failed", snap, err)
 		return err
 	}
 
 	root := aggregate.Root()
 	root.setInternals(snap.ID, snap.Version)
+
+	s.logger.Debug("snapshot applied",
+		LogKeyAggregateType, t,
+		LogKeyAggregateID, aggregateID,
+		LogKeyVersion, snap.Version,
+	)
 	return nil
 }
 
@@ -99,15 +147,39 @@ func (s *Snapper) SaveSnapshot(ctx context.Context, aggregate Aggregate) error {
 		return err
 	}
 
+	timestamp := root.now()
+	if s.nowFunc != nil {
+		timestamp = s.nowFunc()
+	}
+
 	snap := Snapshot{
 		ID:        root.ID(),
-		Timestamp: timeNow(),
+		Timestamp: timestamp,
 		Type:      typ,
 		Version:   root.Version(),
 		State:     buf,
 	}
 
-	return s.store.Save(ctx, &snap)
+	if err := s.store.Save(ctx, &snap); err != nil {
+		s.logSnapshotError("saving snapshot failed", &snap, err)
+		return err
+	}
+
+	s.logger.Debug("snapshot saved",
+		LogKeyAggregateType, typ,
+		LogKeyAggregateID, snap.ID,
+		LogKeyVersion, snap.Version,
+	)
+	return nil
+}
+
+func (s *Snapper) logSnapshotError(msg string, snap *Snapshot, err error) {
+	s.logger.Warn(msg,
+		LogKeyAggregateType, snap.Type,
+		LogKeyAggregateID, snap.ID,
+		LogKeyVersion, snap.Version,
+		LogKeyError, err,
+	)
 }
 
 // validate make sure the aggregate is valid to be saved
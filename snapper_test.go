@@ -3,6 +3,7 @@ package historia
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -18,6 +19,12 @@ func Test_NewSnapper_should_return_valid_instance(t *testing.T) {
 	assert.Equal(t, m, s.marshaller)
 }
 
+func Test_NewSnapper_should_apply_options(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	s := NewSnapper(nil, nil, WithSnapperNowFunc(now))
+	assert.Equal(t, reflect.ValueOf(now).Pointer(), reflect.ValueOf(s.nowFunc).Pointer())
+}
+
 func Test_Snapper_ApplySnapshot_should_return_error_when_aggregate_doesnt_implement_SnapshotTaker(t *testing.T) {
 	s := NewSnapper(nil, nil)
 	assert.ErrorIs(t, s.ApplySnapshot(context.Background(), "d", &ssAggNoSnapshot{}), ErrAggregateDoesntSupportSnapshots)
@@ -190,6 +197,37 @@ func Test_Snapper_SaveSnapshot_should_call_save_on_snapStore(t *testing.T) {
 	assert.ErrorIs(t, s.SaveSnapshot(context.Background(), agg), err)
 }
 
+func Test_Snapper_SaveSnapshot_should_use_clock_of_snapper_or_aggregate(t *testing.T) {
+	t.Parallel()
+
+	aggregateNow := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	snapperNow := aggregateNow.Add(time.Hour)
+
+	var saved *Snapshot
+	ss := &snapStoreMocker{
+		save: func(ctx context.Context, ss *Snapshot) error {
+			saved = ss
+			return nil
+		},
+	}
+	m := &marshalMocker{
+		marshal: func(v interface{}) ([]byte, error) { return nil, nil },
+	}
+
+	agg := &ssAggWithSnapshot{
+		AggregateBase: AggregateBase{id: "yes", version: Version(12)},
+		takeSnapshot:  func() SnapshotBody { return struct{}{} },
+	}
+	agg.SetNowFunc(func() time.Time { return aggregateNow })
+
+	assert.NoError(t, NewSnapper(ss, m).SaveSnapshot(context.Background(), agg))
+	assert.Equal(t, aggregateNow, saved.Timestamp)
+
+	s := NewSnapper(ss, m, WithSnapperNowFunc(func() time.Time { return snapperNow }))
+	assert.NoError(t, s.SaveSnapshot(context.Background(), agg))
+	assert.Equal(t, snapperNow, saved.Timestamp)
+}
+
 // region mocks
 
 type ssAggNoSnapshot struct {
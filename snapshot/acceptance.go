@@ -1,32 +1,279 @@
 package snapshot
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bansukai/historia"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
+// AcceptanceTestSnapshotStore runs the specification of snapshot stores against snapshot
 func AcceptanceTestSnapshotStore(t *testing.T, snapshot historia.SnapshotStore) {
-	expected := historia.Snapshot{
-		Version: 10,
-		ID:      "123",
-		Type:    "Person",
-		State:   []byte{},
+	tests := []struct {
+		title string
+		run   func(ss historia.SnapshotStore) error
+	}{
+		{"should save and get snapshot", saveAndGet},
+		{"should return ErrSnapshotNotFound for unknown snapshots", unknownSnapshot},
+		{"should overwrite snapshot", overwrite},
+		{"should isolate aggregate types sharing an id", typeIsolation},
+		{"should not share state with callers", stateIsolation},
+		{"should keep a consistent snapshot under concurrent saves", concurrentSaves},
+		{"should save large states", largeState},
+		{"should keep timestamps to the microsecond", timestampRoundTrip},
+		{"should read nil and empty states as empty", emptyState},
+		{"should stop on cancelled context", cancelledContext},
 	}
 
-	assert.NoError(t, snapshot.Save(context.Background(), &expected))
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.NoError(t, test.run(snapshot))
+		})
+	}
+}
+
+func saveAndGet(ss historia.SnapshotStore) error {
+	expected := newSnapshot(10, []byte(`{"name":"jane"}`))
+	if err := ss.Save(context.Background(), expected); err != nil {
+		return err
+	}
+
+	actual, err := ss.Get(context.Background(), expected.ID, expected.Type)
+	if err != nil {
+		return err
+	}
+
+	return sameSnapshot(expected, actual)
+}
+
+func unknownSnapshot(ss historia.SnapshotStore) error {
+	if _, err := ss.Get(context.Background(), idFunc(), snapshotType); !errors.Is(err, historia.ErrSnapshotNotFound) {
+		return fmt.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+	return nil
+}
 
-	_, err := snapshot.Get(context.Background(), "bogus", "bogus")
-	assert.ErrorIs(t, err, historia.ErrSnapshotNotFound)
+// overwrite describes saving a snapshot of an aggregate again, the last saved snapshot replaces the previous one
+func overwrite(ss historia.SnapshotStore) error {
+	ctx := context.Background()
+	first := newSnapshot(10, []byte("first"))
+	if err := ss.Save(ctx, first); err != nil {
+		return err
+	}
 
-	actual, err := snapshot.Get(context.Background(), "123", "Person")
+	second := newSnapshot(20, []byte("second"))
+	second.ID = first.ID
+	if err := ss.Save(ctx, second); err != nil {
+		return err
+	}
+
+	actual, err := ss.Get(ctx, first.ID, snapshotType)
+	if err != nil {
+		return err
+	}
 
-	assert.NoError(t, err)
-	assert.Equal(t, expected.ID, actual.ID)
-	assert.Equal(t, expected.Type, actual.Type)
-	assert.Equal(t, expected.Version, actual.Version)
-	assert.Equal(t, expected.Version, actual.Version)
-	assert.Equal(t, expected.State, actual.State)
+	return sameSnapshot(second, actual)
 }
+
+func typeIsolation(ss historia.SnapshotStore) error {
+	ctx := context.Background()
+	person := newSnapshot(1, []byte("person"))
+	company := newSnapshot(2, []byte("company"))
+	company.ID = person.ID
+	company.Type = snapshotType + "Company"
+
+	for _, s := range []*historia.Snapshot{person, company} {
+		if err := ss.Save(ctx, s); err != nil {
+			return err
+		}
+	}
+
+	for _, expected := range []*historia.Snapshot{person, company} {
+		actual, err := ss.Get(ctx, expected.ID, expected.Type)
+		if err != nil {
+			return err
+		}
+		if err := sameSnapshot(expected, actual); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stateIsolation describes the stored state as a copy, changing the slice saved or read doesn't change it
+func stateIsolation(ss historia.SnapshotStore) error {
+	ctx := context.Background()
+	saved := newSnapshot(1, []byte("state"))
+	if err := ss.Save(ctx, saved); err != nil {
+		return err
+	}
+	saved.State[0] = 'X'
+
+	actual, err := ss.Get(ctx, saved.ID, saved.Type)
+	if err != nil {
+		return err
+	}
+	actual.State[0] = 'Y'
+
+	again, err := ss.Get(ctx, saved.ID, saved.Type)
+	if err != nil {
+		return err
+	}
+
+	if string(again.State) != "state" {
+		return fmt.Errorf("expected stored state %q, got %q", "state", again.State)
+	}
+	return nil
+}
+
+// concurrentSaves describes writers racing to save snapshots of the same aggregate,
+// the stored snapshot is one of the saved ones and not a mix of them.
+func concurrentSaves(ss historia.SnapshotStore) error {
+	const writers = 20
+
+	id := idFunc()
+	snapshots := make([]*historia.Snapshot, writers)
+	for i := range snapshots {
+		snapshots[i] = newSnapshot(historia.Version(i+1), []byte(fmt.Sprintf("state-%d", i+1)))
+		snapshots[i].ID = id
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := range snapshots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ss.Save(context.Background(), snapshots[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	actual, err := ss.Get(context.Background(), id, snapshotType)
+	if err != nil {
+		return err
+	}
+
+	if actual.Version == 0 || int(actual.Version) > writers {
+		return fmt.Errorf("unexpected version %d", actual.Version)
+	}
+	return sameSnapshot(snapshots[actual.Version-1], actual)
+}
+
+func largeState(ss historia.SnapshotStore) error {
+	state := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	expected := newSnapshot(1, state)
+	if err := ss.Save(context.Background(), expected); err != nil {
+		return err
+	}
+
+	actual, err := ss.Get(context.Background(), expected.ID, expected.Type)
+	if err != nil {
+		return err
+	}
+	return sameSnapshot(expected, actual)
+}
+
+func timestampRoundTrip(ss historia.SnapshotStore) error {
+	expected := newSnapshot(1, []byte("state"))
+	expected.Timestamp = time.Date(2021, 6, 15, 12, 30, 45, 123456000, time.UTC)
+	if err := ss.Save(context.Background(), expected); err != nil {
+		return err
+	}
+
+	actual, err := ss.Get(context.Background(), expected.ID, expected.Type)
+	if err != nil {
+		return err
+	}
+
+	if !actual.Timestamp.Equal(expected.Timestamp) {
+		return fmt.Errorf("expected timestamp %s, got %s", expected.Timestamp, actual.Timestamp)
+	}
+	return nil
+}
+
+// emptyState describes snapshots without state, stores may not tell a nil state from an empty one
+func emptyState(ss historia.SnapshotStore) error {
+	for _, state := range [][]byte{nil, {}} {
+		expected := newSnapshot(1, state)
+		if err := ss.Save(context.Background(), expected); err != nil {
+			return err
+		}
+
+		actual, err := ss.Get(context.Background(), expected.ID, expected.Type)
+		if err != nil {
+			return err
+		}
+
+		if len(actual.State) != 0 {
+			return fmt.Errorf("expected empty state, got %q", actual.State)
+		}
+	}
+	return nil
+}
+
+// cancelledContext describes calls made with a cancelled context,
+// they return the context error and don't save anything.
+func cancelledContext(ss historia.SnapshotStore) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := newSnapshot(1, []byte("state"))
+	if err := ss.Save(ctx, s); !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected context.Canceled saving, got %v", err)
+	}
+
+	if _, err := ss.Get(context.Background(), s.ID, s.Type); !errors.Is(err, historia.ErrSnapshotNotFound) {
+		return fmt.Errorf("expected nothing saved, got %v", err)
+	}
+
+	if err := ss.Save(context.Background(), s); err != nil {
+		return err
+	}
+
+	if _, err := ss.Get(ctx, s.ID, s.Type); !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected context.Canceled getting, got %v", err)
+	}
+	return nil
+}
+
+func sameSnapshot(expected, actual *historia.Snapshot) error {
+	switch {
+	case actual.ID != expected.ID:
+		return fmt.Errorf("expected id %q, got %q", expected.ID, actual.ID)
+	case actual.Type != expected.Type:
+		return fmt.Errorf("expected type %q, got %q", expected.Type, actual.Type)
+	case actual.Version != expected.Version:
+		return fmt.Errorf("expected version %d, got %d", expected.Version, actual.Version)
+	case !bytes.Equal(actual.State, expected.State):
+		return errors.New("wrong state returned")
+	}
+	return nil
+}
+
+func newSnapshot(version historia.Version, state []byte) *historia.Snapshot {
+	return &historia.Snapshot{
+		ID:        idFunc(),
+		Type:      snapshotType,
+		Version:   version,
+		Timestamp: time.Now().UTC().Truncate(time.Microsecond),
+		State:     state,
+	}
+}
+
+const snapshotType = "Person"
+
+var idFunc = uuid.NewString
@@ -3,37 +3,84 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/bansukai/historia"
 )
 
+type Option func(m *Memory)
+
+// WithLogger sets the logger of the saved snapshots
+func WithLogger(l historia.Logger) Option {
+	return func(m *Memory) {
+		m.logger = l
+	}
+}
+
 // New handler for the snapshot service
-func New() *Memory {
-	return &Memory{
-		store: make(map[string]*historia.Snapshot),
+func New(opts ...Option) *Memory {
+	m := &Memory{
+		store:  make(map[string]historia.Snapshot),
+		logger: historia.NopLogger{},
 	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
 }
 
 // Memory of snapshot store
 type Memory struct {
-	store map[string]*historia.Snapshot
+	store  map[string]historia.Snapshot
+	logger historia.Logger
+	lock   sync.RWMutex
 }
 
-func (h *Memory) Get(_ context.Context, aggregateID string, aggregateType string) (*historia.Snapshot, error) {
+func (h *Memory) Get(ctx context.Context, aggregateID string, aggregateType string) (*historia.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
 	k := formatSnapshotKey(aggregateID, aggregateType)
 	v, ok := h.store[k]
 	if !ok {
 		return nil, historia.ErrSnapshotNotFound
 	}
-	return v, nil
+	return copySnapshot(v), nil
 }
 
-func (h *Memory) Save(_ context.Context, ss *historia.Snapshot) error {
+func (h *Memory) Save(ctx context.Context, ss *historia.Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
 	k := formatSnapshotKey(ss.ID, ss.Type)
-	h.store[k] = ss
+	h.store[k] = *copySnapshot(*ss)
+
+	h.logger.Debug("snapshot saved",
+		historia.LogKeyAggregateType, ss.Type,
+		historia.LogKeyAggregateID, ss.ID,
+		historia.LogKeyVersion, ss.Version,
+	)
 	return nil
 }
 
+// copySnapshot copies the snapshot state, so callers and the store don't share it
+func copySnapshot(ss historia.Snapshot) *historia.Snapshot {
+	if ss.State != nil {
+		ss.State = append([]byte{}, ss.State...)
+	}
+	return &ss
+}
+
 func formatSnapshotKey(id string, t string) string {
 	return fmt.Sprintf("%s_%s", id, t)
 }
@@ -0,0 +1,181 @@
+package historia
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// ksuidEpoch is the KSUID epoch in unix seconds, 2014-05-13T16:53:20Z
+	ksuidEpoch = 1400000000
+)
+
+// NewULIDGenerator returns a generator of ULIDs, 26 character time sortable ids
+// made of a millisecond timestamp and 80 random bits.
+// IDs generated within the same millisecond are monotonically increasing.
+func NewULIDGenerator() func() string {
+	return newULIDGenerator(time.Now, rand.Reader).next
+}
+
+// NewUUIDv7Generator returns a generator of version 7 UUIDs, made of a millisecond timestamp and 74 random bits.
+// IDs generated within the same millisecond are monotonically increasing.
+func NewUUIDv7Generator() func() string {
+	return newUUIDv7Generator(time.Now, rand.Reader).next
+}
+
+// NewKSUIDGenerator returns a generator of KSUIDs, 27 character time sortable ids
+// made of a second timestamp and 128 random bits.
+// IDs generated within the same second are monotonically increasing.
+func NewKSUIDGenerator() func() string {
+	return newKSUIDGenerator(time.Now, rand.Reader).next
+}
+
+func newULIDGenerator(now func() time.Time, entropy io.Reader) *monotonicGenerator {
+	return &monotonicGenerator{
+		nowFunc: now,
+		entropy: entropy,
+		random:  make([]byte, 10),
+		mask:    0xFF,
+		tick:    unixMilli,
+		format: func(tick uint64, random []byte) string {
+			b := make([]byte, 16)
+			putUint48(b, tick)
+			copy(b[6:], random)
+			return encodeBase(b, crockfordAlphabet, 26)
+		},
+	}
+}
+
+func newUUIDv7Generator(now func() time.Time, entropy io.Reader) *monotonicGenerator {
+	return &monotonicGenerator{
+		nowFunc: now,
+		entropy: entropy,
+		random:  make([]byte, 10),
+		// only the 74 lower bits are random, the rest is taken by version and variant
+		mask: 0x03,
+		tick: unixMilli,
+		format: func(tick uint64, random []byte) string {
+			hi := uint64(binary.BigEndian.Uint16(random[:2]))
+			lo := binary.BigEndian.Uint64(random[2:])
+			randA := hi<<2 | lo>>62
+			randB := lo & (1<<62 - 1)
+
+			var u uuid.UUID
+			putUint48(u[:], tick)
+			binary.BigEndian.PutUint16(u[6:], uint16(0x7000|randA))
+			binary.BigEndian.PutUint64(u[8:], 0x8000000000000000|randB)
+			return u.String()
+		},
+	}
+}
+
+func newKSUIDGenerator(now func() time.Time, entropy io.Reader) *monotonicGenerator {
+	return &monotonicGenerator{
+		nowFunc: now,
+		entropy: entropy,
+		random:  make([]byte, 16),
+		mask:    0xFF,
+		tick: func(t time.Time) uint64 {
+			return uint64(t.Unix() - ksuidEpoch)
+		},
+		format: func(tick uint64, random []byte) string {
+			b := make([]byte, 20)
+			binary.BigEndian.PutUint32(b, uint32(tick))
+			copy(b[4:], random)
+			return encodeBase(b, base62Alphabet, 27)
+		},
+	}
+}
+
+// monotonicGenerator generates ids from a timestamp and random bits,
+// incrementing the random bits instead of drawing new ones when the timestamp hasn't moved forward.
+type monotonicGenerator struct {
+	nowFunc func() time.Time
+	entropy io.Reader
+	tick    func(t time.Time) uint64
+	format  func(tick uint64, random []byte) string
+
+	// mask is applied to the first random byte to limit the number of random bits
+	mask byte
+
+	lastTick uint64
+	random   []byte
+	seeded   bool
+	mu       sync.Mutex
+}
+
+func (g *monotonicGenerator) next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tick := g.tick(g.nowFunc())
+	switch {
+	case tick > g.lastTick || !g.seeded:
+		g.seed()
+	case g.increment():
+		// the random bits are exhausted, borrow from the next tick
+		tick = g.lastTick + 1
+		g.seed()
+	default:
+		// the clock stood still or went backwards, stay on the last tick
+		tick = g.lastTick
+	}
+
+	g.lastTick = tick
+	return g.format(tick, g.random)
+}
+
+func (g *monotonicGenerator) seed() {
+	if _, err := io.ReadFull(g.entropy, g.random); err != nil {
+		panic(err)
+	}
+	g.random[0] &= g.mask
+	g.seeded = true
+}
+
+// increment adds one to the random bits and reports if they overflowed
+func (g *monotonicGenerator) increment() bool {
+	for i := len(g.random) - 1; i >= 0; i-- {
+		g.random[i]++
+		if g.random[i] != 0 {
+			return g.random[0]&^g.mask != 0
+		}
+	}
+	return true
+}
+
+func unixMilli(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func putUint48(b []byte, v uint64) {
+	b[0] = byte(v >> 40)
+	b[1] = byte(v >> 32)
+	b[2] = byte(v >> 24)
+	b[3] = byte(v >> 16)
+	b[4] = byte(v >> 8)
+	b[5] = byte(v)
+}
+
+// encodeBase encodes b as a big endian number in the base of the alphabet, left padded to size
+func encodeBase(b []byte, alphabet string, size int) string {
+	n := new(big.Int).SetBytes(b)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+
+	out := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = alphabet[mod.Int64()]
+	}
+	return string(out)
+}
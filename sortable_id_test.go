@@ -0,0 +1,149 @@
+package historia
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ULIDGenerator_should_generate_ulids(t *testing.T) {
+	now := time.UnixMilli(1469918176385)
+	g := newULIDGenerator(func() time.Time { return now }, bytes.NewReader(make([]byte, 10)))
+
+	// the timestamp part from the ULID specification example
+	assert.Equal(t, "01ARYZ6S41"+strings.Repeat("0", 16), g.next())
+	assert.Equal(t, "01ARYZ6S41"+strings.Repeat("0", 15)+"1", g.next())
+}
+
+func Test_UUIDv7Generator_should_generate_version_7_uuids(t *testing.T) {
+	id := NewUUIDv7Generator()()
+
+	u, err := uuid.Parse(id)
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), u.Version())
+	assert.Equal(t, uuid.RFC4122, u.Variant())
+}
+
+func Test_KSUIDGenerator_should_generate_ksuids(t *testing.T) {
+	now := time.Unix(ksuidEpoch, 0)
+	g := newKSUIDGenerator(func() time.Time { return now }, bytes.NewReader(make([]byte, 16)))
+
+	assert.Equal(t, strings.Repeat("0", 27), g.next())
+	assert.Equal(t, strings.Repeat("0", 26)+"1", g.next())
+	assert.Len(t, NewKSUIDGenerator()(), 27)
+}
+
+func Test_SortableIDGenerators_should_be_monotonic_within_same_tick(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	generators := map[string]*monotonicGenerator{
+		"ulid":   newULIDGenerator(clock, rand.Reader),
+		"uuidv7": newUUIDv7Generator(clock, rand.Reader),
+		"ksuid":  newKSUIDGenerator(clock, rand.Reader),
+	}
+
+	for name, g := range generators {
+		g := g
+		t.Run(name, func(t *testing.T) {
+			ids := make([]string, 1000)
+			for i := range ids {
+				ids[i] = g.next()
+			}
+			assert.True(t, sort.StringsAreSorted(ids))
+			assertUnique(t, ids)
+		})
+	}
+}
+
+func Test_SortableIDGenerators_should_borrow_next_tick_on_overflow(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	entropy := bytes.NewReader(bytes.Repeat([]byte{0xFF}, 100))
+
+	generators := map[string]*monotonicGenerator{
+		"ulid":   newULIDGenerator(clock, entropy),
+		"uuidv7": newUUIDv7Generator(clock, entropy),
+		"ksuid":  newKSUIDGenerator(clock, entropy),
+	}
+
+	for name, g := range generators {
+		g := g
+		t.Run(name, func(t *testing.T) {
+			first := g.next()
+			tick := g.lastTick
+
+			second := g.next()
+			assert.Equal(t, tick+1, g.lastTick)
+			assert.Less(t, first, second)
+		})
+	}
+}
+
+func Test_SortableIDGenerators_should_not_go_backwards_with_the_clock(t *testing.T) {
+	now := time.Now()
+	g := newULIDGenerator(func() time.Time { return now }, rand.Reader)
+
+	first := g.next()
+	now = now.Add(-time.Hour)
+	assert.Less(t, first, g.next())
+}
+
+func Test_SortableIDGenerators_should_be_unique_and_sorted_under_concurrency(t *testing.T) {
+	generators := map[string]func() string{
+		"ulid":   NewULIDGenerator(),
+		"uuidv7": NewUUIDv7Generator(),
+		"ksuid":  NewKSUIDGenerator(),
+	}
+
+	for name, next := range generators {
+		next := next
+		t.Run(name, func(t *testing.T) {
+			const workers, perWorker = 8, 500
+
+			var wg sync.WaitGroup
+			results := make([][]string, workers)
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < perWorker; i++ {
+						results[w] = append(results[w], next())
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			var all []string
+			for _, ids := range results {
+				// every worker sees its own ids in increasing order
+				assert.True(t, sort.StringsAreSorted(ids))
+				all = append(all, ids...)
+			}
+			assertUnique(t, all)
+		})
+	}
+}
+
+func Test_SortableIDGenerators_should_panic_when_entropy_fails(t *testing.T) {
+	g := newULIDGenerator(time.Now, bytes.NewReader(nil))
+	assert.PanicsWithValue(t, io.EOF, func() { g.next() })
+}
+
+func assertUnique(t *testing.T, ids []string) {
+	t.Helper()
+
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, len(ids))
+}
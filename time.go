@@ -5,10 +5,16 @@ import (
 )
 
 // timeNow is a global function that returns the current time.
-// It can be changed from the outside via the SetNowFunc function.
+// It can be changed from the outside via the SetNowFunc function,
+// and is used by aggregates and repositories that don't have their own clock.
 var timeNow = time.Now
 
-// SetNowFunc is used to change what time is returned.
+// SetNowFunc is used to change what time is returned by default.
 func SetNowFunc(f func() time.Time) {
 	timeNow = f
 }
+
+// Now returns the current time from the clock set with SetNowFunc
+func Now() time.Time {
+	return timeNow()
+}
@@ -0,0 +1,88 @@
+package historia
+
+import (
+	"context"
+)
+
+// NewTypedRepository creates a repository for a single aggregate type on top of repo,
+// factory returns new empty instances of the aggregate.
+// Operations relying on an optional interface repo doesn't implement fail with ErrOperationNotSupported.
+func NewTypedRepository[A Aggregate](repo Repository, factory func() A) *TypedRepository[A] {
+	return &TypedRepository[A]{
+		repo:    repo,
+		factory: factory,
+	}
+}
+
+// TypedRepository loads and saves aggregates of type A
+type TypedRepository[A Aggregate] struct {
+	repo    Repository
+	factory func() A
+}
+
+// New returns a new aggregate bound to the repository clock and ID generators
+func (r *TypedRepository[A]) New() A {
+	aggregate := r.factory()
+	if binder, ok := r.repo.(Binder); ok {
+		binder.Bind(aggregate)
+	}
+	return aggregate
+}
+
+// Load fetches the aggregate events, and snapshot if any, and builds up the aggregate
+func (r *TypedRepository[A]) Load(ctx context.Context, aggregateID string) (A, error) {
+	aggregate := r.New()
+	if err := r.repo.Get(ctx, aggregateID, aggregate); err != nil {
+		var zero A
+		return zero, err
+	}
+	return aggregate, nil
+}
+
+// Save an aggregate's events
+func (r *TypedRepository[A]) Save(ctx context.Context, aggregate A) error {
+	return r.repo.Save(ctx, aggregate)
+}
+
+// Reload discards the unsaved changes of the aggregate and rebuilds it from the store
+func (r *TypedRepository[A]) Reload(ctx context.Context, aggregate A) error {
+	reloader, ok := r.repo.(Reloader)
+	if !ok {
+		return ErrOperationNotSupported
+	}
+	return reloader.Reload(ctx, aggregate)
+}
+
+// Exists reports whether the aggregate has been saved and not deleted, without building it
+func (r *TypedRepository[A]) Exists(ctx context.Context, aggregateID string) (bool, error) {
+	inspector, ok := r.repo.(AggregateInspector)
+	if !ok {
+		return false, ErrOperationNotSupported
+	}
+	return inspector.Exists(ctx, aggregateID, r.factory())
+}
+
+// CurrentVersion returns the version of the last stored event of the aggregate, without building it
+func (r *TypedRepository[A]) CurrentVersion(ctx context.Context, aggregateID string) (Version, error) {
+	inspector, ok := r.repo.(AggregateInspector)
+	if !ok {
+		return 0, ErrOperationNotSupported
+	}
+	return inspector.CurrentVersion(ctx, aggregateID, r.factory())
+}
+
+// Subscriber bind a function to be called on all events of aggregates of type A
+func (r *TypedRepository[A]) Subscriber(f EventHandlerFunc) *Subscription {
+	return r.repo.SubscriberAggregateType(f, r.factory())
+}
+
+// SubscriberSpecificAggregate bind a function to be called on events of the aggregates of type A with the given IDs
+func (r *TypedRepository[A]) SubscriberSpecificAggregate(f EventHandlerFunc, aggregateIDs ...string) *Subscription {
+	aggregates := make([]Aggregate, len(aggregateIDs))
+	for i, id := range aggregateIDs {
+		aggregate := r.factory()
+		aggregate.Root().id = id
+		aggregates[i] = aggregate
+	}
+	return r.repo.SubscriberSpecificAggregate(f, aggregates...)
+}
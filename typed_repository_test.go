@@ -0,0 +1,139 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewTypedRepository_should_return_valid_instance(t *testing.T) {
+	repo := NewRepository(nil, nil)
+	tr := NewTypedRepository(repo, newRepoAggregate)
+	assert.Equal(t, repo, tr.repo)
+	assert.NotNil(t, tr.factory)
+}
+
+func Test_TypedRepository_New_should_bind_aggregate(t *testing.T) {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo := NewRepository(nil, nil,
+		WithRepositoryNowFunc(func() time.Time { return now }),
+		WithRepositoryIDFunc(func() string { return "typed" }),
+	)
+
+	agg := NewTypedRepository(repo, newRepoAggregate).New()
+	assert.NoError(t, agg.TrackChange(agg, &repoEvent1{}))
+	assert.Equal(t, "typed", agg.ID())
+	assert.Equal(t, now, agg.Events()[0].Timestamp)
+}
+
+func Test_TypedRepository_Load(t *testing.T) {
+	t.Run("should build aggregate from history", func(t *testing.T) {
+		es := &eventStoreMocker{
+			get: func(_ context.Context, id string, aggregateType string, _ Version) ([]Event, error) {
+				assert.Equal(t, "asd", id)
+				assert.Equal(t, formatAggregatePathType(&repoAggregate{}), aggregateType)
+				return []Event{{AggregateID: id, Version: 1, Data: &repoEvent1{Name: "Loaded"}}}, nil
+			},
+		}
+
+		agg, err := NewTypedRepository(NewRepository(es, nil), newRepoAggregate).Load(context.Background(), "asd")
+		assert.NoError(t, err)
+		assert.Equal(t, "Loaded", agg.name)
+		assert.Equal(t, Version(1), agg.Version())
+	})
+
+	t.Run("should return error", func(t *testing.T) {
+		es := &eventStoreMocker{
+			get: func(context.Context, string, string, Version) ([]Event, error) { return nil, ErrNoEvents },
+		}
+
+		agg, err := NewTypedRepository(NewRepository(es, nil), newRepoAggregate).Load(context.Background(), "asd")
+		assert.ErrorIs(t, err, ErrAggregateNotFound)
+		assert.Nil(t, agg)
+	})
+}
+
+func Test_TypedRepository_Save(t *testing.T) {
+	var saved []Event
+	es := &eventStoreMocker{
+		save: func(_ context.Context, events []Event) error {
+			saved = events
+			return nil
+		},
+	}
+
+	tr := NewTypedRepository(NewRepository(es, nil), newRepoAggregate)
+	agg := tr.New()
+	_ = agg.TrackChange(agg, &repoEvent1{Name: "Saved"})
+
+	assert.NoError(t, tr.Save(context.Background(), agg))
+	assert.Len(t, saved, 1)
+	assert.False(t, agg.HasUnsavedEvents())
+}
+
+func Test_TypedRepository_Exists(t *testing.T) {
+	err := errors.New("boom")
+	tests := []struct {
+		title    string
+		events   []Event
+		err      error
+		expected bool
+	}{
+		{"should exist", []Event{{Version: 1}}, nil, true},
+		{"should not exist", nil, ErrNoEvents, false},
+		{"should return error", nil, err, false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.title, func(t *testing.T) {
+			es := &eventStoreMocker{
+				get: func(context.Context, string, string, Version) ([]Event, error) { return test.events, test.err },
+			}
+
+			exists, actual := NewTypedRepository(NewRepository(es, nil), newRepoAggregate).Exists(context.Background(), "asd")
+			assert.Equal(t, test.expected, exists)
+			if test.err != ErrNoEvents {
+				assert.ErrorIs(t, actual, test.err)
+			}
+		})
+	}
+}
+
+func Test_TypedRepository_CurrentVersion(t *testing.T) {
+	es := &streamInfoMocker{info: &StreamInfo{Version: 4}}
+	version, err := NewTypedRepository(NewRepository(es, nil), newRepoAggregate).CurrentVersion(context.Background(), "asd")
+	assert.NoError(t, err)
+	assert.Equal(t, Version(4), version)
+}
+
+func Test_TypedRepository_Subscriber(t *testing.T) {
+	repo := NewRepository(nil, nil)
+	tr := NewTypedRepository(repo, newRepoAggregate)
+
+	var all, specific int
+	tr.Subscriber(func(context.Context, Event) error {
+		all++
+		return nil
+	}).Subscribe()
+	tr.SubscriberSpecificAggregate(func(context.Context, Event) error {
+		specific++
+		return nil
+	}, "first").Subscribe()
+
+	first := &repoAggregate{AggregateBase: AggregateBase{id: "first"}}
+	second := &repoAggregate{AggregateBase: AggregateBase{id: "second"}}
+	assert.NoError(t, repo.Update(context.Background(), first, []Event{{Data: &repoEvent1{}}}))
+	assert.NoError(t, repo.Update(context.Background(), second, []Event{{Data: &repoEvent1{}}}))
+	assert.NoError(t, repo.Update(context.Background(), &esAgg{}, []Event{{Data: &repoEvent1{}}}))
+
+	assert.Equal(t, 2, all)
+	assert.Equal(t, 1, specific)
+}
+
+func newRepoAggregate() *repoAggregate {
+	return &repoAggregate{}
+}
@@ -11,3 +11,9 @@ func TypeOf(i interface{}) string {
 func PathOf(i interface{}) string {
 	return reflect.TypeOf(i).Elem().PkgPath()
 }
+
+// AggregateTypeOf returns the aggregate type events of the aggregate are stored with,
+// the package path and name of its type.
+func AggregateTypeOf(aggregate Aggregate) string {
+	return formatAggregatePathType(aggregate)
+}
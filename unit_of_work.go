@@ -0,0 +1,143 @@
+package historia
+
+import (
+	"context"
+)
+
+// NewUnitOfWork returns an empty UnitOfWork committing to the repository
+func (r *Repo) NewUnitOfWork() *UnitOfWork {
+	return &UnitOfWork{
+		repo: r,
+	}
+}
+
+// CommitHook is called by Commit for every changed aggregate before anything is saved,
+// an error aborts the commit and is returned by it
+type CommitHook func(ctx context.Context, aggregate Aggregate) error
+
+// UnitOfWork tracks several aggregates and saves their changes as a whole
+type UnitOfWork struct {
+	repo       *Repo
+	aggregates []Aggregate
+	hooks      []CommitHook
+}
+
+// AddCommitHook adds hooks called when committing, the ones added last being called first.
+// Repository middlewares add them to the units of work returned by the repository they decorate,
+// to check commits as they do saves, so the outermost middleware is called first.
+func (u *UnitOfWork) AddCommitHook(hooks ...CommitHook) {
+	u.hooks = append(u.hooks, hooks...)
+}
+
+// Track adds aggregates to the unit of work, tracking the same aggregate twice has no effect
+func (u *UnitOfWork) Track(aggregates ...Aggregate) {
+	for _, aggregate := range aggregates {
+		if !u.tracks(aggregate) {
+			if u.repo != nil {
+				u.repo.Bind(aggregate)
+			}
+			u.aggregates = append(u.aggregates, aggregate)
+		}
+	}
+}
+
+// Commit saves the unsaved events of all the tracked aggregates atomically,
+// and publishes them to the subscribers only once all of them are stored.
+//
+// The commit hooks are called first, then the invariants of every aggregate are checked, the aggregate violating them is
+// rolled back and nothing is saved. Changes to more than one aggregate require an AtomicEventStore,
+// otherwise ErrAtomicSaveNotSupported is returned.
+// After a successful commit the unit of work is empty and can be reused.
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	var changed []Aggregate
+	var streams [][]Event
+	for _, aggregate := range u.aggregates {
+		root := aggregate.Root()
+		if !root.HasUnsavedEvents() {
+			continue
+		}
+
+		for i := len(u.hooks) - 1; i >= 0; i-- {
+			if err := u.hooks[i](ctx, aggregate); err != nil {
+				return err
+			}
+		}
+
+		if err := root.checkInvariants(aggregate); err != nil {
+			root.rollback(aggregate)
+			return err
+		}
+
+		// the events are enriched on a copy, the aggregates are left untouched until everything is saved
+		events := root.Events()
+		enrich(ctx, u.repo.enrichers, events)
+		changed = append(changed, aggregate)
+		streams = append(streams, events)
+	}
+
+	// the saves of all the aggregates are observed as a whole, each one within the previous and ending together
+	saveCtx := ctx
+	ends := make([]func(error), len(streams))
+	for i, events := range streams {
+		saveCtx, ends[i] = u.repo.observer.Save(saveCtx, events[0].AggregateType, events[0].AggregateID, len(events))
+	}
+
+	err := u.save(saveCtx, streams)
+	for i := len(ends) - 1; i >= 0; i-- {
+		ends[i](err)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, aggregate := range changed {
+		aggregate.Root().update()
+	}
+	u.aggregates = nil
+
+	// publish the saved events to subscribers
+	for i, aggregate := range changed {
+		if err := u.repo.Update(ctx, aggregate, streams[i]); err != nil {
+			u.repo.logger.Error("publishing committed events failed",
+				LogKeyAggregateType, formatAggregatePathType(aggregate),
+				LogKeyAggregateID, aggregate.Root().ID(),
+				LogKeyError, err,
+			)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Rollback discards the unsaved changes of all the tracked aggregates and empties the unit of work
+func (u *UnitOfWork) Rollback() {
+	for _, aggregate := range u.aggregates {
+		aggregate.Root().DiscardChanges(aggregate)
+	}
+	u.aggregates = nil
+}
+
+func (u *UnitOfWork) save(ctx context.Context, streams [][]Event) error {
+	switch len(streams) {
+	case 0:
+		return nil
+	case 1:
+		return u.repo.eventStore.SaveEvents(ctx, streams[0])
+	}
+
+	store, ok := capability[AtomicEventStore](u.repo.eventStore)
+	if !ok {
+		return ErrAtomicSaveNotSupported
+	}
+	return store.SaveEventStreams(ctx, streams)
+}
+
+func (u *UnitOfWork) tracks(aggregate Aggregate) bool {
+	for _, tracked := range u.aggregates {
+		if tracked.Root() == aggregate.Root() {
+			return true
+		}
+	}
+	return false
+}
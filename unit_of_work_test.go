@@ -0,0 +1,205 @@
+package historia
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UnitOfWork_Track_should_ignore_already_tracked_aggregates(t *testing.T) {
+	uow := NewRepository(nil, nil).NewUnitOfWork()
+	p1, p2 := &repoAggregate{}, &repoAggregate{}
+
+	uow.Track(p1, p2)
+	uow.Track(p1)
+	assert.Len(t, uow.aggregates, 2)
+}
+
+func Test_UnitOfWork_Commit_should_save_all_aggregates_atomically_then_publish(t *testing.T) {
+	var saved [][]Event
+	var published []Event
+	es := &atomicStoreMocker{
+		saveStreams: func(_ context.Context, streams [][]Event) error {
+			assert.Empty(t, published, "events must be published after the commit")
+			saved = streams
+			return nil
+		},
+	}
+
+	repo := NewRepository(es, nil)
+	repo.SubscriberAll(func(_ context.Context, e Event) error {
+		published = append(published, e)
+		return nil
+	}).Subscribe()
+
+	from, to, untouched := &repoAggregate{}, &repoAggregate{}, &repoAggregate{}
+	_ = from.TrackChange(from, &repoEvent1{Name: "debited"})
+	_ = to.TrackChange(to, &repoEvent1{Name: "credited"})
+
+	uow := repo.NewUnitOfWork()
+	uow.Track(from, to, untouched)
+	assert.NoError(t, uow.Commit(context.Background()))
+
+	assert.Len(t, saved, 2)
+	assert.Equal(t, from.ID(), saved[0][0].AggregateID)
+	assert.Equal(t, to.ID(), saved[1][0].AggregateID)
+	assert.Len(t, published, 2)
+
+	assert.False(t, from.HasUnsavedEvents())
+	assert.False(t, to.HasUnsavedEvents())
+	assert.Equal(t, Version(1), from.Version())
+	assert.Empty(t, uow.aggregates)
+}
+
+func Test_UnitOfWork_Commit_should_use_SaveEvents_for_single_aggregate(t *testing.T) {
+	called := false
+	es := &eventStoreMocker{
+		save: func(context.Context, []Event) error {
+			called = true
+			return nil
+		},
+	}
+
+	p := &repoAggregate{}
+	_ = p.TrackChange(p, &repoEvent1{})
+
+	uow := NewRepository(es, nil).NewUnitOfWork()
+	uow.Track(p, &repoAggregate{})
+	assert.NoError(t, uow.Commit(context.Background()))
+	assert.True(t, called)
+}
+
+func Test_UnitOfWork_Commit_should_return_error_when_store_is_not_atomic(t *testing.T) {
+	p1, p2 := &repoAggregate{}, &repoAggregate{}
+	_ = p1.TrackChange(p1, &repoEvent1{})
+	_ = p2.TrackChange(p2, &repoEvent1{})
+
+	uow := NewRepository(&eventStoreMocker{}, nil).NewUnitOfWork()
+	uow.Track(p1, p2)
+	assert.ErrorIs(t, uow.Commit(context.Background()), ErrAtomicSaveNotSupported)
+	assert.True(t, p1.HasUnsavedEvents())
+}
+
+func Test_UnitOfWork_Commit_should_not_save_or_publish_when_store_fails(t *testing.T) {
+	err := errors.New("boom")
+	es := &atomicStoreMocker{
+		saveStreams: func(context.Context, [][]Event) error { return err },
+	}
+
+	repo := NewRepository(es, nil)
+	repo.SubscriberAll(func(context.Context, Event) error {
+		t.Fatal("nothing should be published")
+		return nil
+	}).Subscribe()
+
+	p1, p2 := &repoAggregate{}, &repoAggregate{}
+	_ = p1.TrackChange(p1, &repoEvent1{})
+	_ = p2.TrackChange(p2, &repoEvent1{})
+
+	uow := repo.NewUnitOfWork()
+	uow.Track(p1, p2)
+	assert.ErrorIs(t, uow.Commit(context.Background()), err)
+	assert.True(t, p1.HasUnsavedEvents())
+	assert.True(t, p2.HasUnsavedEvents())
+}
+
+func Test_UnitOfWork_Commit_should_reject_batch_when_invariant_fails(t *testing.T) {
+	es := &atomicStoreMocker{
+		saveStreams: func(context.Context, [][]Event) error {
+			t.Fatal("nothing should be saved")
+			return nil
+		},
+	}
+
+	valid := &repoAggregate{}
+	_ = valid.TrackChange(valid, &repoEvent1{})
+
+	invalid := &invAgg{maxAge: 5}
+	_ = invalid.TrackChange(invalid, &born{Name: "Someone"})
+	invalid.maxAge = -1
+
+	uow := NewRepository(es, nil).NewUnitOfWork()
+	uow.Track(valid, invalid)
+	ctx := WithUserID(context.Background(), "jane")
+	assert.ErrorIs(t, uow.Commit(ctx), ErrInvariantViolation)
+	assert.False(t, invalid.HasUnsavedEvents())
+	assert.True(t, valid.HasUnsavedEvents())
+	assert.Empty(t, valid.Events()[0].Metadata, "the events of the valid aggregate are not enriched")
+}
+
+func Test_UnitOfWork_Commit_should_save_with_observer_context(t *testing.T) {
+	type span struct{}
+	var saved []string
+	es := &atomicStoreMocker{
+		saveStreams: func(ctx context.Context, _ [][]Event) error {
+			saved = append(saved, ctx.Value(span{}).(string))
+			return nil
+		},
+	}
+	es.save = func(ctx context.Context, _ []Event) error {
+		saved = append(saved, ctx.Value(span{}).(string))
+		return nil
+	}
+
+	var ended []string
+	observer := &saveObserverMocker{save: func(ctx context.Context, aggregateID string) (context.Context, func(error)) {
+		return context.WithValue(ctx, span{}, aggregateID), func(error) { ended = append(ended, aggregateID) }
+	}}
+	repo := NewRepository(es, nil, WithRepositoryObserver(observer))
+
+	p1, p2 := &repoAggregate{}, &repoAggregate{}
+	_ = p1.SetID("1")
+	_ = p2.SetID("2")
+	_ = p1.TrackChange(p1, &repoEvent1{})
+	_ = p2.TrackChange(p2, &repoEvent1{})
+
+	uow := repo.NewUnitOfWork()
+	uow.Track(p1, p2)
+	assert.NoError(t, uow.Commit(context.Background()))
+	assert.Equal(t, []string{"2"}, saved)
+	assert.Equal(t, []string{"2", "1"}, ended)
+
+	_ = p1.TrackChange(p1, &repoEvent1{})
+	uow.Track(p1)
+	assert.NoError(t, uow.Commit(context.Background()))
+	assert.Equal(t, []string{"2", "1"}, saved)
+}
+
+func Test_UnitOfWork_Rollback_should_discard_changes(t *testing.T) {
+	p1, p2 := &repoAggregate{}, &repoAggregate{}
+	_ = p1.TrackChange(p1, &repoEvent1{Name: "one"})
+	_ = p2.TrackChange(p2, &repoEvent1{Name: "two"})
+
+	uow := NewRepository(nil, nil).NewUnitOfWork()
+	uow.Track(p1, p2)
+	uow.Rollback()
+
+	assert.False(t, p1.HasUnsavedEvents())
+	assert.False(t, p2.HasUnsavedEvents())
+	assert.Equal(t, "", p1.name)
+	assert.Empty(t, uow.aggregates)
+}
+
+// region mocks
+
+type atomicStoreMocker struct {
+	eventStoreMocker
+	saveStreams func(ctx context.Context, streams [][]Event) error
+}
+
+func (a *atomicStoreMocker) SaveEventStreams(ctx context.Context, streams [][]Event) error {
+	return a.saveStreams(ctx, streams)
+}
+
+type saveObserverMocker struct {
+	NopObserver
+	save func(ctx context.Context, aggregateID string) (context.Context, func(error))
+}
+
+func (o *saveObserverMocker) Save(ctx context.Context, _, aggregateID string, _ int) (context.Context, func(error)) {
+	return o.save(ctx, aggregateID)
+}
+
+// endregion